@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// 疑問手・悪手とする損失（指した側から見た評価値の下がり幅）
+const (
+	dubiousLoss = 150
+	blunderLoss = 300
+)
+
+// 1手の解析結果（評価値は先手から見た値）
+type moveAnalysis struct {
+	Best     int   // 最善手を指した場合の評価値
+	BestMove *Move // 最善手
+	Played   int   // 実際に指した手の評価値
+}
+
+// 指した側から見た損失（最善手との評価値の差）
+func (a moveAnalysis) loss(player Player) int {
+	if player == Second {
+		return a.Played - a.Best
+	}
+	return a.Best - a.Played
+}
+
+// 損失に応じた印（疑問手・悪手）
+func lossMark(loss int) string {
+	switch {
+	case loss >= blunderLoss:
+		return tr("悪手")
+	case loss >= dubiousLoss:
+		return tr("疑問手")
+	}
+	return ""
+}
+
+// 局面bで指し手mを指した場合を最善手と比べる
+func analyzeMove(b *Board, m Move, depth int) moveAnalysis {
+	ctx := context.Background()
+	best, bestMove := b.Minimax(ctx, depth, -999999, 999999, b.CurrentTurn == First)
+	a := moveAnalysis{best, bestMove, best}
+	if bestMove == nil || !movesEqual(bestMove, &m) {
+		next := b.Clone()
+		next.ApplyLegal(m)
+		a.Played, _ = next.Minimax(ctx, depth-1, -999999, 999999, next.CurrentTurn == First)
+	}
+	return a
+}
+
+// 棋譜の各手を解析して注釈を付ける
+// 評価値・最善手と異なる手を指した場合の最善手と損失・疑問手や悪手の印を、各手の注釈に追加する。
+// 疑問手と悪手には、最善手を1手の変化として加える。
+func annotateRecord(r *Record, depth int) {
+	b := r.Initial.Clone()
+	for i, m := range r.Moves {
+		a := analyzeMove(b, m, depth)
+		note := &r.Notes[i]
+		score := a.Played
+		note.Score = &score
+
+		if loss := a.loss(b.CurrentTurn); loss > 0 && a.BestMove != nil {
+			comment := fmt.Sprintf(tr("最善手 %s（評価値 %d）"), csaMove(b, *a.BestMove), a.Best)
+			if mark := lossMark(loss); mark != "" {
+				comment = mark + " " + comment
+				r.AddVariation(i, []Move{*a.BestMove})
+			}
+			if note.Comment != "" {
+				comment = note.Comment + "\n" + comment
+			}
+			note.Comment = comment
+		}
+		b.ApplyLegal(m)
+	}
+}
+
+// export サブコマンド: 棋譜をCSA形式で書き出す
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	annotated := fs.Bool("annotated", false, "各手を解析して評価値・最善手・悪手の印を注釈として付け、評価値の推移のグラフを添える")
+	depth := fs.Int("depth", 4, "解析の探索深度")
+	out := fs.String("o", "", "書き出すファイル（省略すると標準出力）")
+	kif := fs.Bool("kif", false, "KIF形式で書き出す（各手の消費時間とその累計も書く）")
+	setUsage(fs, "使い方: mini-syogi export [-annotated] [-depth N] [-kif] [-o ファイル] 棋譜.csa")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || *depth < 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	r, err := loadRecord(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("棋譜を読み込めません:"), err)
+		return exitError
+	}
+	if *annotated {
+		annotateRecord(r, *depth)
+		if len(r.Moves) > 0 {
+			r.Trailer = evalGraph(r.scores())
+			// 書き出し先がファイルなら、グラフは画面にも表示する
+			if *out != "" {
+				fmt.Println(strings.Join(r.Trailer, "\n"))
+			}
+		}
+	}
+
+	write := r.WriteCSA
+	if *kif {
+		write = r.WriteKIF
+	}
+	if *out == "" {
+		err = write(os.Stdout)
+	} else {
+		err = writeFile(*out, write)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("棋譜を書き出せません:"), err)
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package main
+
+// マスに指定したプレイヤーの駒の利きがあるか
+// 手を生成せず、マスから外側へ駒を探すので王手の判定に使っても軽い。
+func (b *Board) IsAttacked(row, col int, by Player) bool {
+	rules := b.rules()
+	for _, d := range kingSteps {
+		// 隣のマスからの利き
+		r, c := row-d[0], col-d[1]
+		if b.isInBoard(r, c) {
+			if p := b.Cells[r][c]; p.Owner == by && hasDir(rules.Steps(p), d[0], d[1]) {
+				return true
+			}
+		}
+
+		// 飛び駒の利き（d方向へ最初にある駒が、逆向きに飛んでくるか）
+		for i := 1; ; i++ {
+			r, c := row+d[0]*i, col+d[1]*i
+			if !b.isInBoard(r, c) {
+				break
+			}
+			p := b.Cells[r][c]
+			if p.Owner == None {
+				continue
+			}
+			if p.Owner == by && hasDir(rules.Slides(p), -d[0], -d[1]) {
+				return true
+			}
+			break
+		}
+	}
+	return false
+}
+
+// 方向の一覧に(dr, dc)が含まれるか
+func hasDir(dirs [][2]int, dr, dc int) bool {
+	for _, d := range dirs {
+		if d[0] == dr && d[1] == dc {
+			return true
+		}
+	}
+	return false
+}
+
+// 駒が利いているマスを順にfに渡す（味方の駒があるマスも含む）
+func (b *Board) forEachAttackedSquare(row, col int, f func(r, c int)) {
+	p := b.Cells[row][col]
+	if p.Owner == None {
+		return
+	}
+	rules := b.rules()
+
+	// 隣のマス
+	for _, d := range rules.Steps(p) {
+		if b.isInBoard(row+d[0], col+d[1]) {
+			f(row+d[0], col+d[1])
+		}
+	}
+
+	// 飛び駒
+	for _, d := range rules.Slides(p) {
+		for i := 1; ; i++ {
+			r, c := row+d[0]*i, col+d[1]*i
+			if !b.isInBoard(r, c) {
+				break
+			}
+			f(r, c)
+			if b.Cells[r][c].Owner != None {
+				break
+			}
+		}
+	}
+}
+
+// 利きの地図（各マスに利いている指定したプレイヤーの駒の数）
+func (b *Board) AttackMap(player Player) [][]int {
+	m := make([][]int, b.Size())
+	for r := range m {
+		m[r] = make([]int, b.Size())
+	}
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			if b.Cells[r][c].Owner == player {
+				b.forEachAttackedSquare(r, c, func(ar, ac int) {
+					m[ar][ac]++
+				})
+			}
+		}
+	}
+	return m
+}
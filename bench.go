@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ベンチマークの局面（初期局面、対局の途中、練習問題の局面）
+var benchPositions = []string{
+	"rbsgk/4p/5/P4/KGSBR b - 1",
+	"rbsgk/4p/1B3/P1S2/KG2R b - 5",
+	"rb1k1/2s1p/1B3/P1S2/KG3 b Gr 9",
+	"3k1/r1s1p/5/P1S2/KG3 b Brbg 13",
+	"3k1/1rs1p/P4/1BS2/KG2r b bg 17",
+	"2k2/psp2/5/1S3/2r1K b G 1",
+	"5/k4/2+R2/1s3/3K1 b G2P 1",
+}
+
+// ベンチマークの既定の探索深度
+const benchDepth = 4
+
+// bench サブコマンド: 決まった局面を決まった深さまで探索し、局面数と速度を表示する
+// 局面数は探索を変えない限り毎回同じになるので、探索の変更を確かめるのに使う。
+func runBench(args []string) int {
+	fs := flag.NewFlagSet("bench", flag.ContinueOnError)
+	depth := fs.Int("depth", benchDepth, "探索深度")
+	setUsage(fs, "使い方: mini-syogi bench [-depth N]")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *depth < 1 {
+		fmt.Fprintln(os.Stderr, tr("探索深度は1以上です:"), *depth)
+		return exitUsage
+	}
+
+	total := 0
+	start := time.Now()
+	for i, sfen := range benchPositions {
+		b, err := ParseSFEN(sfen)
+		if err != nil {
+			panic(err)
+		}
+		s := &searcher{ctx: context.Background(), root: b.CurrentTurn}
+		eval, move := s.minimax(b, *depth, -999999, 999999, b.CurrentTurn == First)
+		best := tr("なし")
+		if move != nil {
+			best = csaMove(b, *move)
+		}
+		fmt.Printf(tr("局面 %d/%d: 局面数 %d 評価値 %d 最善手 %s")+"\n", i+1, len(benchPositions), s.nodes, eval, best)
+		total += s.nodes
+	}
+	elapsed := time.Since(start)
+	nps := int(float64(total) / elapsed.Seconds())
+	fmt.Printf("\n"+tr("合計 %d 局面 %v %d nps")+"\n", total, elapsed.Round(time.Millisecond), nps)
+	return 0
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// 持ち駒の枚数の漢数字
+var kanjiNumbers = []string{"", "一", "二", "三", "四", "五", "六", "七", "八", "九", "十"}
+
+// BOD形式の持ち駒の並び順
+var bodHandOrder = []PieceType{Rook, Bishop, Gold, Silver, Pawn}
+
+// BOD形式の持ち駒（例: 角　歩二）
+func bodHand(hand []PieceType) string {
+	counts := make(map[PieceType]int)
+	for _, p := range hand {
+		counts[p]++
+	}
+	parts := []string{}
+	for _, pType := range bodHandOrder {
+		switch n := counts[pType]; {
+		case n == 1:
+			parts = append(parts, kanjiSymbols[pType])
+		case n > 1 && n < len(kanjiNumbers):
+			parts = append(parts, kanjiSymbols[pType]+kanjiNumbers[n])
+		}
+	}
+	if len(parts) == 0 {
+		return "なし"
+	}
+	return strings.Join(parts, "　")
+}
+
+// BOD形式の盤面図を書き出し（筋は一般的な表記に合わせて右から数える）
+func (b *Board) WriteBOD(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "後手の持駒：%s\n", bodHand(b.SecondHand))
+	files := ""
+	for col := b.Size() - 1; col >= 0; col-- {
+		files += " " + kifFiles[col]
+	}
+	frame := "+" + strings.Repeat("---", b.Size()) + "+"
+	fmt.Fprintln(bw, " "+files)
+	fmt.Fprintln(bw, frame)
+	for i := 0; i < b.Size(); i++ {
+		fmt.Fprint(bw, "|")
+		for j := 0; j < b.Size(); j++ {
+			p := b.Cells[i][j]
+			switch p.Owner {
+			case None:
+				fmt.Fprint(bw, " ・")
+			case First:
+				fmt.Fprint(bw, " "+kanjiSymbols[p.Type])
+			case Second:
+				fmt.Fprint(bw, "v"+kanjiSymbols[p.Type])
+			}
+		}
+		fmt.Fprintf(bw, "|%s\n", rankNames[i])
+	}
+	fmt.Fprintln(bw, frame)
+	fmt.Fprintf(bw, "先手の持駒：%s\n", bodHand(b.FirstHand))
+	if b.CurrentTurn == Second {
+		fmt.Fprintln(bw, "後手番")
+	} else {
+		fmt.Fprintln(bw, "先手番")
+	}
+	return bw.Flush()
+}
+
+// 漢字から駒の種類を取得
+func kanjiPieceType(s string) (PieceType, bool) {
+	if s == "王" {
+		return King, true
+	}
+	for pType, sym := range kanjiSymbols {
+		if sym == s {
+			return pType, true
+		}
+	}
+	return Empty, false
+}
+
+// BOD形式の持ち駒を解析
+func parseBODHand(s string) ([]PieceType, error) {
+	hand := []PieceType{}
+	s = strings.TrimSpace(s)
+	if s == "なし" || s == "" {
+		return hand, nil
+	}
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == '　' || r == ' ' }) {
+		runes := []rune(part)
+		pType, ok := kanjiPieceType(string(runes[0]))
+		if !ok || pType == King || pType != baseType(pType) {
+			return nil, fmt.Errorf(tr("持ち駒が不正です: %s"), part)
+		}
+		count := 1
+		if len(runes) > 1 {
+			count = 0
+			for n, k := range kanjiNumbers {
+				if n > 0 && k == string(runes[1:]) {
+					count = n
+				}
+			}
+			if count == 0 {
+				return nil, fmt.Errorf(tr("持ち駒の枚数が不正です: %s"), part)
+			}
+		}
+		for i := 0; i < count; i++ {
+			hand = append(hand, pType)
+		}
+	}
+	return hand, nil
+}
+
+// BOD形式の盤面図を読み込み（盤の大きさは段の数から決める）
+func ReadBOD(r io.Reader) (*Board, error) {
+	return readBOD(r, nil)
+}
+
+// rulesの盤面図を読み込み（段の数がrulesの盤の大きさと違えばエラー。rulesがnilなら標準のルール）
+func readBOD(r io.Reader, rules Rules) (*Board, error) {
+	var rows []string
+	firstHand, secondHand := []PieceType{}, []PieceType{}
+	turn := First
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		var err error
+		switch {
+		case strings.HasPrefix(line, "後手の持駒："):
+			secondHand, err = parseBODHand(strings.TrimPrefix(line, "後手の持駒："))
+		case strings.HasPrefix(line, "先手の持駒："):
+			firstHand, err = parseBODHand(strings.TrimPrefix(line, "先手の持駒："))
+		case line == "後手番":
+			turn = Second
+		case line == "先手番":
+			turn = First
+		case strings.HasPrefix(line, "|"):
+			rows = append(rows, line)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	b, err := newBoardOfSize(len(rows), rules)
+	if err != nil {
+		return nil, err
+	}
+	b.FirstHand, b.SecondHand, b.CurrentTurn = firstHand, secondHand, turn
+	for row, line := range rows {
+		if err := parseBODRow(line, b, row); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// 盤面の1段（例: |v飛v角v銀v金v玉|一）を解析
+func parseBODRow(line string, b *Board, row int) error {
+	runes := []rune(line)
+	if len(runes) < 2+2*b.Size() || runes[1+2*b.Size()] != '|' {
+		return fmt.Errorf(tr("%s段目の形式が不正です"), rankNames[row])
+	}
+	for col := 0; col < b.Size(); col++ {
+		mark, sym := runes[1+col*2], string(runes[2+col*2])
+		if sym == "・" {
+			b.Cells[row][col] = Piece{Empty, None}
+			continue
+		}
+		pType, ok := kanjiPieceType(sym)
+		if !ok {
+			return fmt.Errorf(tr("%s段目の駒が不正です: %s"), rankNames[row], sym)
+		}
+		owner := First
+		if mark == 'v' {
+			owner = Second
+		}
+		b.Cells[row][col] = Piece{pType, owner}
+	}
+	return nil
+}
+
+// 盤面図ファイルを読み込み
+func loadBOD(path string) (*Board, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBOD(f)
+}
@@ -0,0 +1,517 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 定跡（局面ごとに、指す手とその手を選ぶ重み）
+// 局面は手数を除いたSFEN形式、指し手はCSA形式で持つ。
+type Book struct {
+	Rules     string                `json:"rules,omitempty"` // 変則ルールの名前（標準のルールなら空）
+	Positions map[string][]BookMove `json:"positions"`
+}
+
+// 定跡の指し手
+type BookMove struct {
+	Move   string `json:"move"`   // CSA形式の指し手（例: +5554FU）
+	Weight int    `json:"weight"` // 選ぶ重み（大きいほど選ばれやすい。0なら選ばない）
+}
+
+// 定跡の局面の見出し（手数を除いたSFEN形式）
+func bookKey(b *Board) string {
+	fields := strings.Fields(b.SFEN(1))
+	return strings.Join(fields[:3], " ")
+}
+
+// 定跡ファイルを読み込む（なければ空の定跡）
+func loadBook(path string) (*Book, error) {
+	bk := &Book{Positions: map[string][]BookMove{}}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return bk, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(bk); err != nil {
+		return nil, err
+	}
+	if bk.Positions == nil {
+		bk.Positions = map[string][]BookMove{}
+	}
+	return bk, nil
+}
+
+// 定跡ファイルに保存
+func saveBook(path string, bk *Book) error {
+	return writeFile(path, func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(bk)
+	})
+}
+
+// 局面の定跡手
+func (bk *Book) Moves(b *Board) []BookMove {
+	return bk.Positions[bookKey(b)]
+}
+
+// 定跡手を加える（すでにあれば重みを足す）
+func (bk *Book) Add(b *Board, m Move, weight int) {
+	key, text := bookKey(b), csaMove(b, m)
+	moves := bk.Positions[key]
+	for i := range moves {
+		if moves[i].Move == text {
+			moves[i].Weight += weight
+			bk.sort(key)
+			return
+		}
+	}
+	bk.Positions[key] = append(moves, BookMove{text, weight})
+	bk.sort(key)
+}
+
+// 定跡手の重みを変える（定跡になければfalse）
+func (bk *Book) SetWeight(b *Board, m Move, weight int) bool {
+	key, text := bookKey(b), csaMove(b, m)
+	for i, bm := range bk.Positions[key] {
+		if bm.Move == text {
+			bk.Positions[key][i].Weight = weight
+			bk.sort(key)
+			return true
+		}
+	}
+	return false
+}
+
+// 定跡手を除く（定跡になければfalse。手がなくなった局面は消す）
+func (bk *Book) Remove(b *Board, m Move) bool {
+	key, text := bookKey(b), csaMove(b, m)
+	moves := bk.Positions[key]
+	for i, bm := range moves {
+		if bm.Move == text {
+			moves = append(moves[:i], moves[i+1:]...)
+			if len(moves) == 0 {
+				delete(bk.Positions, key)
+			} else {
+				bk.Positions[key] = moves
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// 別の定跡を取り込む（同じ局面の同じ手は重みを足す）
+func (bk *Book) Merge(other *Book) error {
+	if other.Rules != bk.Rules {
+		return fmt.Errorf(tr("ルールの違う定跡は取り込めません（%q と %q）"), bk.Rules, other.Rules)
+	}
+	for key, moves := range other.Positions {
+		for _, om := range moves {
+			found := false
+			for i, bm := range bk.Positions[key] {
+				if bm.Move == om.Move {
+					bk.Positions[key][i].Weight += om.Weight
+					found = true
+					break
+				}
+			}
+			if !found {
+				bk.Positions[key] = append(bk.Positions[key], om)
+			}
+		}
+		bk.sort(key)
+	}
+	return nil
+}
+
+// 棋譜の最初のplies手を定跡に加える（sideの指した手だけ。Noneなら両方）
+func (bk *Book) AddRecord(r *Record, plies int, side Player) {
+	b := r.Initial.Clone()
+	for _, m := range r.Moves[:min(plies, len(r.Moves))] {
+		if side == None || b.CurrentTurn == side {
+			bk.Add(b, m, 1)
+		}
+		b.ApplyLegal(m)
+	}
+}
+
+// 重みがminに満たない定跡手を除く（手がなくなった局面は消す）
+func (bk *Book) Prune(min int) {
+	for key, moves := range bk.Positions {
+		kept := moves[:0]
+		for _, bm := range moves {
+			if bm.Weight >= min {
+				kept = append(kept, bm)
+			}
+		}
+		if len(kept) == 0 {
+			delete(bk.Positions, key)
+		} else {
+			bk.Positions[key] = kept
+		}
+	}
+}
+
+// 棋譜の勝者（投了・詰み・時間切れ・反則・トライで終わっていれば、最後に手番だった側の相手。それ以外はNone）
+func recordWinner(r *Record) Player {
+	switch r.End {
+	case "%TORYO", "%TSUMI", "%TIME_UP", "%ILLEGAL_MOVE", "%TRY":
+		return r.Position(len(r.Moves)).CurrentTurn.Opponent()
+	}
+	return None
+}
+
+// 定跡を作る棋譜の選び方
+type bookBuildOptions struct {
+	Plies    int    // 棋譜の最初の何手までを使うか
+	Winner   bool   // 勝った側の手だけを使う（勝敗のつかなかった棋譜は使わない）
+	Player   string // この対局者の手だけを使う（空ならすべて）
+	MinGames int    // この局数より少ない棋譜にしか現れない手は除く
+	MinDepth int    // 探索深度がこれより浅いAIの手は除く（0なら人間の手も使う）
+}
+
+// 棋譜のどちらの側の手を使うか（Noneなら両方。使わない棋譜ならfalse）
+func (o bookBuildOptions) side(r *Record) (Player, bool) {
+	var use [3]bool
+	for _, p := range []Player{First, Second} {
+		name := r.FirstName
+		if p == Second {
+			name = r.SecondName
+		}
+		use[p] = (!o.Winner || recordWinner(r) == p) &&
+			(o.Player == "" || name == o.Player) &&
+			r.Depths[p] >= o.MinDepth
+	}
+	switch {
+	case use[First] && use[Second]:
+		return None, true
+	case use[First]:
+		return First, true
+	case use[Second]:
+		return Second, true
+	}
+	return None, false
+}
+
+// フォルダ（サブフォルダも含む）のCSA形式とKIF形式の棋譜から定跡を作る
+// 使った棋譜と除いた棋譜の数も返す（読めない棋譜やルールの違う棋譜は除く）。
+func buildBook(dir, rules string, opts bookBuildOptions) (bk *Book, used, skipped int, err error) {
+	bk = &Book{Rules: rules, Positions: map[string][]BookMove{}}
+	unreadable, err := walkRecords(dir, func(r *Record) {
+		side, ok := opts.side(r)
+		if !ok || r.Initial.rules().Name() != rules {
+			skipped++
+			return
+		}
+		bk.AddRecord(r, opts.Plies, side)
+		used++
+	})
+	bk.Prune(opts.MinGames)
+	return bk, used, skipped + unreadable, err
+}
+
+// 局面の定跡手を重みの大きい順（同じならCSA形式の順）に並べる
+func (bk *Book) sort(key string) {
+	moves := bk.Positions[key]
+	sort.SliceStable(moves, func(i, j int) bool {
+		if moves[i].Weight != moves[j].Weight {
+			return moves[i].Weight > moves[j].Weight
+		}
+		return moves[i].Move < moves[j].Move
+	})
+}
+
+// フォルダ（サブフォルダも含む）のCSA形式とKIF形式の棋譜を順にvisitに渡す
+// 読めない棋譜はエラーを表示して除き、その数を返す。
+func walkRecords(dir string, visit func(r *Record)) (skipped int, err error) {
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if !strings.EqualFold(filepath.Ext(path), ".csa") && !isKIFFile(path) {
+			return nil
+		}
+		r, err := loadRecord(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			skipped++
+			return nil
+		}
+		visit(r)
+		return nil
+	})
+	return skipped, err
+}
+
+// 定跡の局面と指し手がルールに合っているか調べる（見つけた誤りをすべて返す）
+func (bk *Book) Check() []error {
+	rules, err := ParseRules(bk.Rules)
+	if err != nil {
+		return []error{err}
+	}
+	var keys []string
+	for key := range bk.Positions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		b, err := ParseSFEN(key)
+		if err == nil {
+			b.Rules = rules
+			err = b.checkPosition()
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		for _, bm := range bk.Positions[key] {
+			if bm.Weight < 0 {
+				errs = append(errs, fmt.Errorf(tr("%s: %s の重みが負です"), key, bm.Move))
+			}
+			if _, err := parseCSAMove(b, bm.Move); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			}
+		}
+	}
+	return errs
+}
+
+// 局面の定跡手から重みに応じて1手選ぶ（定跡になければfalse）
+func (bk *Book) Choose(b *Board) (Move, bool) {
+	if bk.Rules != b.rules().Name() {
+		return Move{}, false
+	}
+	total := 0
+	for _, bm := range bk.Moves(b) {
+		total += max(bm.Weight, 0)
+	}
+	if total == 0 {
+		return Move{}, false
+	}
+	n := rand.Intn(total)
+	for _, bm := range bk.Moves(b) {
+		if n -= max(bm.Weight, 0); n < 0 {
+			m, err := parseCSAMove(b, bm.Move)
+			return m, err == nil
+		}
+	}
+	return Move{}, false
+}
+
+// book サブコマンド: 定跡ファイルを編集する
+func runBook(args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, tr("使い方: mini-syogi book show|add|remove|weight|merge|check ..."))
+		fmt.Fprintln(os.Stderr, tr("  book show [-sfen 局面] [-moves 指し手...] <定跡ファイル>"))
+		fmt.Fprintln(os.Stderr, tr("  book add [-weight N] [-sfen 局面] [-moves 指し手...] <定跡ファイル> <指し手>"))
+		fmt.Fprintln(os.Stderr, tr("  book remove [-sfen 局面] [-moves 指し手...] <定跡ファイル> <指し手>"))
+		fmt.Fprintln(os.Stderr, tr("  book weight [-sfen 局面] [-moves 指し手...] <定跡ファイル> <指し手> <重み>"))
+		fmt.Fprintln(os.Stderr, tr("  book merge <定跡ファイル> <取り込む定跡ファイル>..."))
+		fmt.Fprintln(os.Stderr, tr("  book check <定跡ファイル>"))
+		fmt.Fprintln(os.Stderr, tr("  book build [-plies N] [-winner] [-player 名前] [-min-depth N] [-min-games N] [-rules ルール] <定跡ファイル> <棋譜のフォルダ>"))
+		return exitUsage
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+	action, args := args[0], args[1:]
+
+	fs := flag.NewFlagSet("book "+action, flag.ContinueOnError)
+	sfen := fs.String("sfen", "", "定跡の局面（省略すると初期局面）")
+	moves := fs.String("moves", "", "局面まで指す手を空白区切りで並べる（-sfen の局面か初期局面から）")
+	weight := fs.Int("weight", 1, "add で加える手の重み")
+	plies := fs.Int("plies", 10, "build で棋譜の最初の何手までを使うか")
+	winner := fs.Bool("winner", false, "build で勝った側の手だけを使う")
+	player := fs.String("player", "", "build でこの対局者の手だけを使う")
+	minGames := fs.Int("min-games", 1, "build でこの局数より少ない棋譜にしか現れない手を除く")
+	minDepth := fs.Int("min-depth", 0, "build で探索深度がこれより浅いAIの手と人間の手を除く（0なら除かない）")
+	rules := fs.String("rules", "", "build で使う棋譜の変則ルール（nodrops など。省略すると標準のルール）")
+	fs.Usage = func() {
+		usage()
+		printDefaults(fs, nil)
+	}
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	want := map[string]int{"show": 1, "add": 2, "remove": 2, "weight": 3, "check": 1, "build": 2}[action]
+	if action == "merge" {
+		want = max(fs.NArg(), 2)
+	}
+	if want == 0 || fs.NArg() != want {
+		return usage()
+	}
+	path := fs.Arg(0)
+	if action == "build" {
+		if *plies < 1 || *minGames < 1 {
+			fmt.Fprintln(os.Stderr, tr("-plies と -min-games は1以上です"))
+			return exitUsage
+		}
+		if _, err := ParseRules(*rules); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return exitUsage
+		}
+		bk, used, skipped, err := buildBook(fs.Arg(1), *rules, bookBuildOptions{*plies, *winner, *player, *minGames, *minDepth})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("棋譜を読み込めません:"), err)
+			return exitError
+		}
+		fmt.Printf(tr("%d局の棋譜から定跡を作りました（除いた棋譜 %d局）")+"\n", used, skipped)
+		return writeBook(path, bk)
+	}
+	bk, err := loadBook(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("定跡を読み込めません:"), err)
+		return exitError
+	}
+
+	switch action {
+	case "check":
+		errs := bk.Check()
+		for _, err := range errs {
+			fmt.Println(err)
+		}
+		if len(errs) > 0 {
+			fmt.Printf(tr("%d件の誤りがあります")+"\n", len(errs))
+			return exitError
+		}
+		fmt.Printf(tr("%d局面の定跡に誤りはありません")+"\n", len(bk.Positions))
+		return 0
+	case "merge":
+		for _, other := range fs.Args()[1:] {
+			ob, err := loadBook(other)
+			if err == nil {
+				err = bk.Merge(ob)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, tr("%s を取り込めません: %v")+"\n", other, err)
+				return exitError
+			}
+		}
+		return writeBook(path, bk)
+	}
+
+	b, err := bookPosition(bk, *sfen, *moves)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("局面が不正です:"), err)
+		return exitUsage
+	}
+	if action == "show" {
+		b.Display(os.Stdout)
+		printBookMoves(os.Stdout, bk, b)
+		return 0
+	}
+
+	m, err := parseCorrespondenceMove(b, fs.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("その手は指せません:"), err)
+		return exitError
+	}
+	switch action {
+	case "add":
+		if *weight < 0 {
+			fmt.Fprintln(os.Stderr, tr("重みは0以上です:"), *weight)
+			return exitUsage
+		}
+		bk.Add(b, m, *weight)
+	case "remove":
+		if !bk.Remove(b, m) {
+			fmt.Fprintln(os.Stderr, tr("定跡にない手です:"), csaMove(b, m))
+			return exitError
+		}
+	case "weight":
+		w, err := strconv.Atoi(fs.Arg(2))
+		if err != nil || w < 0 {
+			fmt.Fprintln(os.Stderr, tr("重みは0以上の整数です:"), fs.Arg(2))
+			return exitUsage
+		}
+		if !bk.SetWeight(b, m, w) {
+			fmt.Fprintln(os.Stderr, tr("定跡にない手です:"), csaMove(b, m))
+			return exitError
+		}
+	}
+	printBookMoves(os.Stdout, bk, b)
+	return writeBook(path, bk)
+}
+
+// 定跡の局面（-sfen の局面か初期局面から -moves の手を指した局面。定跡のルールで指す）
+func bookPosition(bk *Book, sfen, moves string) (*Board, error) {
+	rules, err := ParseRules(bk.Rules)
+	if err != nil {
+		return nil, err
+	}
+	b := rules.Setup()
+	if sfen != "" {
+		if b, err = ParseSFEN(sfen); err != nil {
+			return nil, err
+		}
+		if bk.Rules != "" {
+			b.Rules = rules
+		}
+		if err := b.checkPosition(); err != nil {
+			return nil, err
+		}
+	}
+	for _, s := range strings.Fields(moves) {
+		m, err := parseCorrespondenceMove(b, s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", s, err)
+		}
+		b.ApplyLegal(m)
+	}
+	return b, nil
+}
+
+// 局面の定跡手を重みの割合とともに表示
+func printBookMoves(w io.Writer, bk *Book, b *Board) {
+	moves := bk.Moves(b)
+	if len(moves) == 0 {
+		fmt.Fprintln(w, tr("定跡手はありません"))
+		return
+	}
+	total := 0
+	for _, bm := range moves {
+		total += bm.Weight
+	}
+	for _, bm := range moves {
+		text := bm.Move
+		if m, err := parseCSAMove(b, bm.Move); err == nil {
+			text = playerMark(b.CurrentTurn) + moveText(&m)
+		}
+		percent := 0
+		if total > 0 {
+			percent = bm.Weight * 100 / total
+		}
+		fmt.Fprintf(w, tr("%s（%s） 重み %d（%d%%）")+"\n", text, bm.Move, bm.Weight, percent)
+	}
+}
+
+// 定跡ファイルに保存して結果を終了コードにする
+func writeBook(path string, bk *Book) int {
+	if err := saveBook(path, bk); err != nil {
+		fmt.Fprintln(os.Stderr, tr("定跡を保存できません:"), err)
+		return exitError
+	}
+	fmt.Printf(tr("定跡を保存しました: %s（%d局面）")+"\n", path, len(bk.Positions))
+	return 0
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 自動で指すプレイヤー（局面を受け取り、指す手を返す）
+// 自作のAIは ChooseMove だけを書いて bots に登録すれば、-bot で対局や通信対局に出せる。
+// 返す手は合法手であること（指せない手を返すと反則負けになる）。
+type Bot interface {
+	ChooseMove(p Position) Move
+}
+
+// 関数をそのままBotとして使う
+type BotFunc func(p Position) Move
+
+func (f BotFunc) ChooseMove(p Position) Move {
+	return f(p)
+}
+
+// -bot で選べるボット
+var bots = map[string]Bot{
+	"random": BotFunc(randomBot),
+	"greedy": BotFunc(greedyBot),
+}
+
+// 登録済みのボットの名前（表示用に並べる）
+func botNames() []string {
+	var names []string
+	for name := range bots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 合法手から無作為に選ぶボット
+func randomBot(p Position) Move {
+	moves := p.LegalMoves()
+	return moves[rand.Intn(len(moves))]
+}
+
+// 指した直後の評価値が最も良い手を選ぶボット（1手だけ読む）
+func greedyBot(p Position) Move {
+	moves := p.LegalMoves()
+	best, bestScore := moves[0], 0
+	for i, m := range moves {
+		score := p.Play(m).Evaluate()
+		if p.Turn() == Second {
+			score = -score
+		}
+		if i == 0 || score > bestScore {
+			best, bestScore = m, score
+		}
+	}
+	return best
+}
+
+// 外部のプログラムのボット（bot パッケージの Run で作ったプログラムと、標準入出力の行でやり取りする）
+// 局面ごとに合法手と指した後の局面・評価値を送り、CSA形式の指し手を1行受け取る。
+type externalBot struct {
+	name string
+	cmd  *exec.Cmd
+	in   io.WriteCloser
+	out  *bufio.Scanner
+}
+
+// ボットのプログラムを起動（commandは空白で区切ったプログラムと引数）
+func startExternalBot(command string) (*externalBot, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, errors.New(tr("コマンドが空です"))
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stderr = os.Stderr
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &externalBot{name: filepath.Base(args[0]), cmd: cmd, in: in, out: bufio.NewScanner(out)}, nil
+}
+
+// 局面を送って指し手を受け取る（答えられなければ指せない手を返し、反則負けにする）
+func (e *externalBot) ChooseMove(p Position) Move {
+	b := p.Board()
+	w := bufio.NewWriter(e.in)
+	fmt.Fprintf(w, "position %s %d\n", b.SFEN(1), p.Evaluate())
+	for _, m := range p.LegalMoves() {
+		next := p.Play(m)
+		fmt.Fprintf(w, "move %s %s %d\n", csaMove(b, m), next.b.SFEN(1), next.Evaluate())
+	}
+	fmt.Fprintln(w, "go")
+	if w.Flush() != nil || !e.out.Scan() {
+		return Move{}
+	}
+	m, err := parseCSAMove(b, strings.TrimSpace(e.out.Text()))
+	if err != nil {
+		return Move{}
+	}
+	return m
+}
+
+// プログラムに終了を伝えて終わるのを待つ
+func (e *externalBot) Close() error {
+	fmt.Fprintln(e.in, "quit")
+	e.in.Close()
+	return e.cmd.Wait()
+}
+
+// 人間の代わりにボットを指させる（人間同士ならボットは後手、通信対局なら自分の側）
+func (g *Game) UseBot(name string, bot Bot) error {
+	side := Second
+	switch {
+	case g.SelfPlay:
+		return errors.New(tr("AI同士の対局ではボットを使えません"))
+	case g.Remote != nil:
+		side = g.remoteSide.Opponent()
+	case g.AIPlayer != None:
+		side = g.AIPlayer.Opponent()
+	}
+	g.bot, g.botSide = bot, side
+	g.players[side] = name
+	g.setNames()
+	return nil
+}
+
+// ボットに手を選ばせる（時間切れや中断で待つのをやめたらnil。指せない手なら反則負け）
+func (g *Game) botMove(ctx context.Context) *Move {
+	pos := NewPosition(g.Board)
+	if len(pos.LegalMoves()) == 0 {
+		return nil
+	}
+	chosen := make(chan Move, 1)
+	go func() {
+		chosen <- g.bot.ChooseMove(pos)
+	}()
+	select {
+	case m := <-chosen:
+		if err := g.Board.ValidateMove(m); err != nil {
+			g.printError(errorCode(err, "illegal_move"), fmt.Sprintf(tr("ボットが指せない手を返しました: %s"), moveText(&m)))
+			g.Result = winResult(g.botSide.Opponent(), ReasonIllegalMove)
+			return nil
+		}
+		fmt.Fprintf(g.chat(), tr("ボット: %s")+"\n", g.moveText(&m))
+		return &m
+	case <-ctx.Done():
+		return nil
+	}
+}
@@ -0,0 +1,146 @@
+// Package bot は、mini-syogi の対局に自作のAIを外部のプログラムとして出すためのパッケージ。
+//
+// ChooseMove を1つ書いて Run に渡すだけで、mini-syogi の -bot-cmd から起動されるプログラムになる。
+//
+//	func main() {
+//		bot.Run(bot.BotFunc(func(p bot.Position) bot.Move {
+//			return p.LegalMoves()[0]
+//		}))
+//	}
+//
+// 局面は mini-syogi から標準入力の行で届き、選んだ手を標準出力に1行で返す。
+//
+//	position <SFEN> <評価値>          手番の局面と、その評価値（先手から見た値）
+//	move <指し手> <SFEN> <評価値>      合法手ごとに、指した後の局面とその評価値
+//	go                               手を選ぶ（<指し手> の1行で答える）
+//	quit                             終了する
+//
+// 指し手はCSA形式（例: +5453FU）。合法手の生成と評価は mini-syogi が行うので、
+// 読めるのは1手先の局面（Play で得た局面の LegalMoves は空）までになる。
+package bot
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// 手番
+type Player int
+
+const (
+	First  Player = 1 // 先手
+	Second Player = 2 // 後手
+)
+
+// 指し手（CSA形式。例: +5453FU、打つ手は +0053FU）
+type Move string
+
+// 局面（mini-syogi から届いた局面と、合法手を指した後の局面）
+type Position struct {
+	sfen  string
+	eval  int
+	moves []Move
+	next  map[Move]Position
+}
+
+// 局面のSFEN
+func (p Position) SFEN() string {
+	return p.sfen
+}
+
+// 手番
+func (p Position) Turn() Player {
+	if fields := strings.Fields(p.sfen); len(fields) > 1 && fields[1] == "w" {
+		return Second
+	}
+	return First
+}
+
+// 合法手（Playで得た1手先の局面では空）
+func (p Position) LegalMoves() []Move {
+	return append([]Move{}, p.moves...)
+}
+
+// 合法手を指した後の局面（合法手でなければ空の局面）
+func (p Position) Play(m Move) Position {
+	return p.next[m]
+}
+
+// 評価値（先手から見た値）
+func (p Position) Evaluate() int {
+	return p.eval
+}
+
+// 自作のAI（局面を受け取り、合法手から指す手を選ぶ）
+type Bot interface {
+	ChooseMove(p Position) Move
+}
+
+// 関数をそのままBotとして使う
+type BotFunc func(p Position) Move
+
+func (f BotFunc) ChooseMove(p Position) Move {
+	return f(p)
+}
+
+// 標準入出力で mini-syogi とやり取りしてbotに指させる（quit か入力の終わりで終える）
+func Run(b Bot) error {
+	return Serve(os.Stdin, os.Stdout, b)
+}
+
+// rから局面を読み、botの選んだ手をwに書く
+func Serve(r io.Reader, w io.Writer, b Bot) error {
+	var pos Position
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "position":
+			p, err := parsePosition(fields[1:])
+			if err != nil {
+				return err
+			}
+			pos = p
+			pos.next = map[Move]Position{}
+		case "move":
+			if len(fields) < 2 {
+				return fmt.Errorf("指し手の行が不正です: %s", scanner.Text())
+			}
+			next, err := parsePosition(fields[2:])
+			if err != nil {
+				return err
+			}
+			m := Move(fields[1])
+			pos.moves = append(pos.moves, m)
+			pos.next[m] = next
+		case "go":
+			if _, err := fmt.Fprintln(w, b.ChooseMove(pos)); err != nil {
+				return err
+			}
+		case "quit":
+			return nil
+		default:
+			return fmt.Errorf("解釈できない行です: %s", scanner.Text())
+		}
+	}
+	return scanner.Err()
+}
+
+// 「<SFEN> <評価値>」を解析（SFENは空白を含む4項目）
+func parsePosition(fields []string) (Position, error) {
+	if len(fields) != 5 {
+		return Position{}, fmt.Errorf("局面の形式が不正です: %s", strings.Join(fields, " "))
+	}
+	eval, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return Position{}, fmt.Errorf("評価値が不正です: %s", fields[4])
+	}
+	return Position{sfen: strings.Join(fields[:4], " "), eval: eval}, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 対局時計
+// 端末の表示とは切り離してあり、ゲームループや探索の時間管理から共通に使う。
+type Clock interface {
+	Start(p Player)                   // pの時計を動かす（動いている時計は止める）
+	Stop() time.Duration              // 動いている時計を止め、その手の消費時間を返す
+	Remaining(p Player) time.Duration // 残り時間
+	OnFlag(f func(p Player))          // 持ち時間が切れたときに呼ばれる関数を登録
+}
+
+// 切れ負けの対局時計
+type SuddenDeathClock struct {
+	mu        sync.Mutex
+	now       TimeSource
+	remaining [3]time.Duration // 残り時間（Playerで添字）
+	running   Player           // 動いている側（止まっていればNone）
+	started   time.Time
+	timer     Timer
+	onFlag    []func(Player)
+}
+
+// 両者の持ち時間を指定して時計を作成
+func NewSuddenDeathClock(limit time.Duration, now TimeSource) *SuddenDeathClock {
+	c := &SuddenDeathClock{now: now}
+	c.remaining[First], c.remaining[Second] = limit, limit
+	return c
+}
+
+// 残り時間を設定（棋譜の続きから対局する場合など）
+func (c *SuddenDeathClock) Set(p Player, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.remaining[p] = d
+}
+
+func (c *SuddenDeathClock) Start(p Player) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stopLocked()
+	c.running = p
+	c.started = c.now.Now()
+	c.timer = c.now.AfterFunc(c.remaining[p], func() { c.flag(p) })
+}
+
+func (c *SuddenDeathClock) Stop() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stopLocked()
+}
+
+func (c *SuddenDeathClock) stopLocked() time.Duration {
+	if c.running == None {
+		return 0
+	}
+	c.timer.Stop()
+	elapsed := c.now.Now().Sub(c.started)
+	c.remaining[c.running] -= elapsed
+	c.running = None
+	return elapsed
+}
+
+func (c *SuddenDeathClock) Remaining(p Player) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := c.remaining[p]
+	if c.running == p {
+		d -= c.now.Now().Sub(c.started)
+	}
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
+func (c *SuddenDeathClock) OnFlag(f func(p Player)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onFlag = append(c.onFlag, f)
+}
+
+// 時間切れを通知
+func (c *SuddenDeathClock) flag(p Player) {
+	c.mu.Lock()
+	if c.running != p {
+		// 通知の直前に止められた
+		c.mu.Unlock()
+		return
+	}
+	handlers := append([]func(Player){}, c.onFlag...)
+	c.mu.Unlock()
+
+	for _, f := range handlers {
+		f(p)
+	}
+}
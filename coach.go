@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// コーチモードの狙いを探す探索深度と、狙いとして表示する評価値の上昇幅
+const (
+	threatDepth = 2
+	threatGain  = 200
+)
+
+// 取られそうな駒（相手の利きがあり、味方の利きがない駒。玉は除く）
+func (b *Board) HangingPieces(player Player) [][2]int {
+	var squares [][2]int
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			p := b.Cells[r][c]
+			if p.Owner != player || p.Type == King {
+				continue
+			}
+			if b.IsAttacked(r, c, player.Opponent()) && !b.IsAttacked(r, c, player) {
+				squares = append(squares, [2]int{r, c})
+			}
+		}
+	}
+	return squares
+}
+
+// 手番を相手に渡した局面（相手の狙いを調べるのに使う）
+func (b *Board) nullMove() *Board {
+	next := b.Clone()
+	next.CurrentTurn = next.CurrentTurn.Opponent()
+	return next
+}
+
+// 手番の側がパスした場合に相手が1手で詰ませられる手
+func (b *Board) MateThreat() *Move {
+	if b.InCheck() {
+		return nil
+	}
+	opp := b.nullMove()
+	var mate *Move
+	opp.ForEachLegalMove(func(m Move) bool {
+		next := opp.Clone()
+		next.ApplyLegal(m)
+		if next.IsCheckmate() {
+			mate = &m
+			return false
+		}
+		return true
+	})
+	return mate
+}
+
+// 手番の側がパスした場合に相手が得をする手（両取りなど）
+func (b *Board) Threat() *Move {
+	if b.InCheck() {
+		return nil
+	}
+	opp := b.nullMove()
+	maximizing := opp.CurrentTurn == First
+	score, move := opp.Minimax(context.Background(), threatDepth, -999999, 999999, maximizing)
+	gain := score - opp.Evaluate()
+	if !maximizing {
+		gain = -gain
+	}
+	if move == nil || gain < threatGain {
+		return nil
+	}
+	return move
+}
+
+// コーチモード: 指す前に取られそうな駒と相手の狙いを表示
+func (g *Game) printCoach(w io.Writer) {
+	board := g.Board
+	player := board.CurrentTurn
+	for _, sq := range board.HangingPieces(player) {
+		p := board.Cells[sq[0]][sq[1]]
+		fmt.Fprintf(w, tr("注意: %sの%sにひもが付いていません")+"\n",
+			g.squareText(sq[0], sq[1]), currentTheme.pieceName(p.Type))
+	}
+	if m := board.MateThreat(); m != nil {
+		fmt.Fprintf(w, tr("注意: 詰めろです（相手の狙い: %s）")+"\n", g.moveText(m))
+	} else if m := board.Threat(); m != nil {
+		fmt.Fprintf(w, tr("注意: 相手の狙い: %s")+"\n", g.moveText(m))
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+// サブコマンド（引数を受け取り、終了コードを返す）
+var commands = map[string]func(args []string) int{
+	"about":    runAbout,
+	"bench":    runBench,
+	"book":     runBook,
+	"export":   runExport,
+	"move":     runMove,
+	"perft":    runPerft,
+	"problems": runProblems,
+	"puzzle":   runPuzzle,
+	"replay":   runReplay,
+	"spsa":     runSPSA,
+	"tune":     runTune,
+	"tutorial": runTutorial,
+	"version":  runVersion,
+}
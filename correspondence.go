@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// move サブコマンド: 棋譜ファイルに1手だけ指して保存する（メールやチャットでの通信対局用）
+// 棋譜ファイルがなければ初期局面から新しい対局を始める。終局したら終了コードは対局の結果、対局が続くなら0（続くことは出力で示す）。
+func runMove(args []string) int {
+	fs := flag.NewFlagSet("move", flag.ContinueOnError)
+	reply := fs.Bool("reply", false, "続けてAIが1手指す")
+	depth := fs.Int("depth", defaultDepth, "AIの探索深度")
+	nodes := fs.Int("nodes", 0, "AIが探索する局面の数の上限（0なら制限しない）")
+	setUsage(fs, "使い方: mini-syogi move [-reply] [-depth N] [-nodes N] <棋譜ファイル> <指し手>")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return exitUsage
+	}
+	path, input := fs.Arg(0), fs.Arg(1)
+
+	r, err := loadRecord(path)
+	if os.IsNotExist(err) {
+		fmt.Println(tr("新しい対局を始めます"))
+		r, err = NewRecord(NewBoard()), nil
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("棋譜を読み込めません:"), err)
+		return exitError
+	}
+	if r.End != "" {
+		fmt.Fprintf(os.Stderr, tr("この対局は終わっています（%s）")+"\n", r.End)
+		return exitError
+	}
+
+	g := &Game{Board: r.Position(len(r.Moves)), Record: r, out: os.Stdout}
+	if input == "resign" || input == "投了" {
+		g.Result = winResult(g.Board.CurrentTurn.Opponent(), ReasonResign)
+	} else {
+		move, err := parseCorrespondenceMove(g.Board, input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("その手は指せません:"), err)
+			return exitError
+		}
+		g.Board.ApplyLegal(move)
+		r.Add(move, 0)
+		g.Result = g.judge()
+
+		if *reply && !g.Result.Decided() {
+			result := g.Board.Analyze(context.Background(), SearchOptions{
+				Depth:   *depth,
+				Nodes:   *nodes,
+				history: g.positionCounts(),
+				Ply:     len(r.Moves),
+			})
+			if result.Move != nil {
+				g.printAIMove(result.Move)
+				g.Board.ApplyLegal(*result.Move)
+				r.Add(*result.Move, 0)
+				r.Notes[len(r.Notes)-1].Score = &result.Score
+				g.Result = g.judge()
+			}
+		}
+	}
+
+	g.Board.Display(os.Stdout)
+	if g.Result.Decided() {
+		fmt.Println("\n" + g.Result.String())
+		r.End = g.Result.csaEnd()
+	} else {
+		fmt.Println("\n" + tr("対局は続いています"))
+		if g.Board.CurrentTurn == First {
+			fmt.Println(tr("次は先手の番です"))
+		} else {
+			fmt.Println(tr("次は後手の番です"))
+		}
+	}
+	if err := saveRecord(path, r); err != nil {
+		fmt.Fprintln(os.Stderr, tr("棋譜を保存できません:"), err)
+		return exitError
+	}
+	if !g.Result.Decided() {
+		return 0
+	}
+	return g.exitCode()
+}
+
+// 通信対局の指し手（対局中と同じ 5133・4142+・p53 の形式か、CSA形式の +1211NG）
+// 対局中と違って成るかを尋ねられないので、成・不成を指定せずに成らないと指せない手は成る手として扱う。
+func parseCorrespondenceMove(b *Board, input string) (Move, error) {
+	if strings.HasPrefix(input, "+") || strings.HasPrefix(input, "-") {
+		return parseCSAMove(b, input)
+	}
+	move := parseInput(input, b)
+	if move == nil {
+		return Move{}, fmt.Errorf(tr("指し手の形式が不正です: %s"), input)
+	}
+	if err := b.ValidateMove(*move); err != nil {
+		if _, _, explicit := cutPromotion(input); explicit {
+			return Move{}, fmt.Errorf("%s", illegalReason(err))
+		}
+		move.Promote = true
+		if canChoosePromote(b, move) && b.ValidateMove(*move) == nil {
+			return *move, nil
+		}
+		return Move{}, fmt.Errorf("%s", illegalReason(err))
+	}
+	return *move, nil
+}
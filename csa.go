@@ -0,0 +1,555 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 棋譜
+type Record struct {
+	FirstName  string          // 先手の対局者名
+	SecondName string          // 後手の対局者名
+	Initial    *Board          // 開始局面
+	Moves      []Move          // 指し手
+	Times      []time.Duration // 各手の消費時間
+	Notes      []Annotation    // 各手の注釈
+	End        string          // 終局の特殊手（%TORYO など）
+	Trailer    []string        // 棋譜の最後に書くコメント（評価値のグラフなど。読み込むと失われる）
+	Variations []Variation     // 本譜から分かれた変化（分かれる手数の順。変化の中の変化はその変化が持つ）
+	Depths     [3]int          // 各側のAIの探索深度（人間の側と分からない側は0）
+
+	counts map[string]int // 現れた各局面の出現回数（nilならまだ数えていない）
+	last   *Board         // 最後の局面（countsとともにAddで進める）
+}
+
+// 指し手の注釈
+type Annotation struct {
+	Comment string // コメント（複数行可。CSA形式の '* 行）
+	Score   *int   // AIの評価値（先手から見た値。CSA形式の '** 行）
+}
+
+// 開始局面から棋譜を作成
+func NewRecord(initial *Board) *Record {
+	return &Record{Initial: initial.Clone()}
+}
+
+// 指定した手数まで進めた局面
+func (r *Record) Position(ply int) *Board {
+	return r.positionAfter(r.Moves, ply)
+}
+
+// 指定した手数までの棋譜の写し（変化を指すときに元の棋譜を残すために使う）
+func (r *Record) Branch(ply int) *Record {
+	return &Record{
+		FirstName:  r.FirstName,
+		SecondName: r.SecondName,
+		Initial:    r.Initial.Clone(),
+		Moves:      append([]Move{}, r.Moves[:ply]...),
+		Times:      append([]time.Duration{}, r.Times[:min(ply, len(r.Times))]...),
+		Notes:      append([]Annotation{}, r.Notes[:min(ply, len(r.Notes))]...),
+	}
+}
+
+// 指し手を追加
+func (r *Record) Add(move Move, elapsed time.Duration) {
+	r.Moves = append(r.Moves, move)
+	r.Times = append(r.Times, elapsed)
+	r.Notes = append(r.Notes, Annotation{})
+	if r.counts != nil {
+		r.last.ApplyLegal(move)
+		r.counts[r.last.positionKey()]++
+	}
+}
+
+// 対局者ごとの消費時間の累計
+func (r *Record) usedTimes() [3]time.Duration {
+	var used [3]time.Duration
+	player := r.Initial.CurrentTurn
+	for _, t := range r.Times {
+		used[player] += t
+		player = player.Opponent()
+	}
+	return used
+}
+
+// 直前の手にコメントを追加（既にコメントがあれば改行して続ける）
+func (r *Record) AddComment(text string) bool {
+	return r.CommentAt(len(r.Notes)-1, text)
+}
+
+// i番目（0始まり）の手にコメントを追加（既にコメントがあれば改行して続ける）
+func (r *Record) CommentAt(i int, text string) bool {
+	if i < 0 || i >= len(r.Notes) {
+		return false
+	}
+	note := &r.Notes[i]
+	if note.Comment != "" {
+		note.Comment += "\n"
+	}
+	note.Comment += text
+	return true
+}
+
+// CSA形式の駒の表記
+var csaPieces = map[PieceType]string{
+	King:           "OU",
+	Gold:           "KI",
+	Silver:         "GI",
+	Bishop:         "KA",
+	Rook:           "HI",
+	Pawn:           "FU",
+	PromotedSilver: "NG",
+	PromotedBishop: "UM",
+	PromotedRook:   "RY",
+	PromotedPawn:   "TO",
+}
+
+// CSA形式の駒の表記から駒の種類を取得
+func csaPieceType(s string) (PieceType, bool) {
+	for pType, code := range csaPieces {
+		if code == s {
+			return pType, true
+		}
+	}
+	return Empty, false
+}
+
+// CSA形式の手番の記号
+func csaSign(p Player) string {
+	if p == Second {
+		return "-"
+	}
+	return "+"
+}
+
+// CSA形式の座標（筋は盤の右から数える）
+func (b *Board) csaSquare(row, col int) string {
+	return fmt.Sprintf("%d%d", b.Size()-col, row+1)
+}
+
+// CSA形式の座標を盤面の行・列に変換
+func (b *Board) parseCSASquare(s string) (row, col int, ok bool) {
+	if len(s) != 2 || !isDigit(s[0]) || !isDigit(s[1]) {
+		return 0, 0, false
+	}
+	file, rank := int(s[0]-'0'), int(s[1]-'0')
+	if file < 1 || file > b.Size() || rank < 1 || rank > b.Size() {
+		return 0, 0, false
+	}
+	return rank - 1, b.Size() - file, true
+}
+
+// 指し手をCSA形式に変換（盤面は指す前の局面）
+func csaMove(b *Board, m Move) string {
+	if m.IsDrop {
+		return csaSign(b.CurrentTurn) + "00" + b.csaSquare(m.ToRow, m.ToCol) + csaPieces[m.DropPiece]
+	}
+	pType := b.Cells[m.FromRow][m.FromCol].Type
+	if m.Promote {
+		pType = promotedType(pType)
+	}
+	return csaSign(b.CurrentTurn) + b.csaSquare(m.FromRow, m.FromCol) + b.csaSquare(m.ToRow, m.ToCol) + csaPieces[pType]
+}
+
+// CSA形式の指し手を解析して合法手と照合
+func parseCSAMove(b *Board, s string) (Move, error) {
+	if len(s) != 7 {
+		return Move{}, fmt.Errorf(tr("指し手の形式が不正です: %s"), s)
+	}
+	if s[:1] != csaSign(b.CurrentTurn) {
+		return Move{}, fmt.Errorf(tr("手番が違います: %s"), s)
+	}
+	toRow, toCol, ok := b.parseCSASquare(s[3:5])
+	if !ok {
+		return Move{}, fmt.Errorf(tr("移動先が不正です: %s"), s)
+	}
+	pType, ok := csaPieceType(s[5:7])
+	if !ok {
+		return Move{}, fmt.Errorf(tr("駒の種類が不正です: %s"), s)
+	}
+
+	// 駒の表記は、打つ手なら持ち駒（成っていない駒）、動かす手なら動かす駒か、それが成った駒に限る
+	var move Move
+	if s[1:3] == "00" {
+		if baseType(pType) != pType {
+			return Move{}, fmt.Errorf(tr("成り駒は打てません: %s"), s)
+		}
+		move = Move{-1, -1, toRow, toCol, true, pType, false}
+	} else {
+		fromRow, fromCol, ok := b.parseCSASquare(s[1:3])
+		if !ok {
+			return Move{}, fmt.Errorf(tr("移動元が不正です: %s"), s)
+		}
+		piece := b.Cells[fromRow][fromCol]
+		promote := false
+		if piece.Owner != None && pType != piece.Type {
+			if promotedType(piece.Type) != pType {
+				return Move{}, fmt.Errorf(tr("駒の種類が移動元の駒と違います: %s"), s)
+			}
+			promote = true
+		}
+		move = Move{fromRow, fromCol, toRow, toCol, false, Empty, promote}
+	}
+
+	if err := b.ValidateMove(move); err != nil {
+		return Move{}, fmt.Errorf("%s: %v", s, err)
+	}
+	return move, nil
+}
+
+// CSA形式の持ち駒行（例: P+00FU00KI）
+func csaHand(sign string, hand []PieceType) string {
+	line := "P" + sign
+	for _, p := range hand {
+		line += "00" + csaPieces[p]
+	}
+	return line
+}
+
+// CSA形式で書き出し
+func (r *Record) WriteCSA(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "V2.2")
+	fmt.Fprintf(bw, "'%s\n", programID())
+	if r.FirstName != "" {
+		fmt.Fprintf(bw, "N+%s\n", r.FirstName)
+	}
+	if r.SecondName != "" {
+		fmt.Fprintf(bw, "N-%s\n", r.SecondName)
+	}
+
+	for _, p := range []Player{First, Second} {
+		if r.Depths[p] > 0 {
+			fmt.Fprintf(bw, "'DEPTH%s %d\n", csaSign(p), r.Depths[p])
+		}
+	}
+
+	// 変則ルール
+	if name := r.Initial.rules().Name(); name != "" {
+		fmt.Fprintf(bw, "'VARIANT %s\n", name)
+	}
+
+	// 開始局面（標準の初期配置でなければSFENもコメントとして残す）
+	if sfen := r.Initial.SFEN(1); sfen != NewBoard().SFEN(1) {
+		fmt.Fprintf(bw, "'SFEN %s\n", sfen)
+	}
+	for i := 0; i < r.Initial.Size(); i++ {
+		fmt.Fprintf(bw, "P%d", i+1)
+		for j := 0; j < r.Initial.Size(); j++ {
+			p := r.Initial.Cells[i][j]
+			if p.Owner == None {
+				fmt.Fprint(bw, " * ")
+			} else {
+				fmt.Fprint(bw, csaSign(p.Owner)+csaPieces[p.Type])
+			}
+		}
+		fmt.Fprintln(bw)
+	}
+	if len(r.Initial.FirstHand) > 0 {
+		fmt.Fprintln(bw, csaHand("+", r.Initial.FirstHand))
+	}
+	if len(r.Initial.SecondHand) > 0 {
+		fmt.Fprintln(bw, csaHand("-", r.Initial.SecondHand))
+	}
+	fmt.Fprintln(bw, csaSign(r.Initial.CurrentTurn))
+
+	// 指し手
+	b := r.Initial.Clone()
+	for i, m := range r.Moves {
+		fmt.Fprintln(bw, csaMove(b, m))
+		if i < len(r.Times) {
+			fmt.Fprintf(bw, "T%d\n", int(r.Times[i].Seconds()))
+		}
+		if i < len(r.Notes) {
+			writeCSANote(bw, r.Notes[i])
+		}
+		b.ApplyLegal(m)
+	}
+	if r.End != "" {
+		fmt.Fprintln(bw, r.End)
+	}
+	r.walkVariations(func(_ *Variation, moves []Move) {
+		fmt.Fprintln(bw, r.csaVariation(moves))
+	})
+	for _, line := range r.Trailer {
+		fmt.Fprintf(bw, "'%s\n", line)
+	}
+	return bw.Flush()
+}
+
+// 注釈をCSA形式のコメント行で書き出し
+func writeCSANote(w io.Writer, note Annotation) {
+	if note.Score != nil {
+		fmt.Fprintf(w, "'** %d\n", *note.Score)
+	}
+	if note.Comment != "" {
+		for _, line := range strings.Split(note.Comment, "\n") {
+			fmt.Fprintf(w, "'*%s\n", line)
+		}
+	}
+}
+
+// CSA形式の棋譜を読み込み（指し手は合法手かどうか検証する）
+func ReadCSA(rd io.Reader) (*Record, error) {
+	r := &Record{}
+	initial := newEmptyBoard(Minishogi{}.Size())
+	var board *Board // 開始局面が確定した後の現在局面
+
+	scanner := bufio.NewScanner(rd)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if variant, ok := strings.CutPrefix(line, "'VARIANT "); ok {
+			rules, err := ParseRules(variant)
+			if err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+			if rules.Size() != initial.Size() {
+				// 'VARIANT 行は開始局面より前に書くので、ルールの大きさの盤に置き直す
+				initial = newEmptyBoard(rules.Size())
+			}
+			initial.Rules = rules
+			continue
+		}
+		if depth, ok := strings.CutPrefix(line, "'DEPTH"); ok && depth != "" {
+			if err := r.readDepth(depth[:1], depth[1:]); err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "'VARIATION "); ok {
+			if board == nil {
+				return nil, fmt.Errorf(tr("%d行目: 開始局面の前に変化があります"), lineNo)
+			}
+			if err := r.readVariation(v); err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+			continue
+		}
+		if strings.HasPrefix(line, "'") {
+			// コメントは「,」で区切らない
+			if err := r.readComment(line); err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+			continue
+		}
+		for _, stmt := range strings.Split(line, ",") {
+			stmt = strings.TrimRight(stmt, " ")
+			if stmt == "" || stmt[0] == 'V' || stmt[0] == '$' {
+				continue
+			}
+			if err := r.readStatement(stmt, initial, &board); err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if board == nil {
+		return nil, errors.New(tr("手番の行がありません"))
+	}
+	return r, nil
+}
+
+// AIの探索深度の行を読み込み（signは手番の記号、textは深さ）
+func (r *Record) readDepth(sign, text string) error {
+	depth, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || depth < 0 {
+		return fmt.Errorf(tr("探索深度が不正です: %s"), text)
+	}
+	switch sign {
+	case "+":
+		r.Depths[First] = depth
+	case "-":
+		r.Depths[Second] = depth
+	default:
+		return fmt.Errorf(tr("手番の記号が不正です: %s"), sign)
+	}
+	return nil
+}
+
+// コメント行を読み込み（指し手の後の '* と '** は注釈として残し、それ以外は読み飛ばす）
+func (r *Record) readComment(line string) error {
+	if len(r.Notes) == 0 {
+		return nil
+	}
+	note := &r.Notes[len(r.Notes)-1]
+	switch {
+	case strings.HasPrefix(line, "'**"):
+		fields := strings.Fields(line[3:])
+		if len(fields) == 0 {
+			return nil
+		}
+		score, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return fmt.Errorf(tr("評価値が不正です: %s"), line)
+		}
+		note.Score = &score
+	case strings.HasPrefix(line, "'*"):
+		r.AddComment(line[2:])
+	}
+	return nil
+}
+
+func (r *Record) readStatement(stmt string, initial *Board, board **Board) error {
+	switch {
+	case strings.HasPrefix(stmt, "N+"):
+		r.FirstName = stmt[2:]
+	case strings.HasPrefix(stmt, "N-"):
+		r.SecondName = stmt[2:]
+
+	case stmt[0] == 'P':
+		if *board != nil {
+			return errors.New(tr("指し手の後に局面があります"))
+		}
+		return readCSAPosition(stmt, initial)
+
+	case stmt == "+" || stmt == "-":
+		if *board != nil {
+			return errors.New(tr("手番が重複しています"))
+		}
+		initial.CurrentTurn = First
+		if stmt == "-" {
+			initial.CurrentTurn = Second
+		}
+		r.Initial = initial.Clone()
+		*board = initial.Clone()
+
+	case stmt[0] == '+' || stmt[0] == '-':
+		if *board == nil {
+			return errors.New(tr("開始局面の手番がありません"))
+		}
+		move, err := parseCSAMove(*board, stmt)
+		if err != nil {
+			return err
+		}
+		r.Moves = append(r.Moves, move)
+		r.Times = append(r.Times, 0)
+		r.Notes = append(r.Notes, Annotation{})
+		(*board).ApplyLegal(move)
+
+	case stmt[0] == 'T':
+		sec, err := strconv.Atoi(stmt[1:])
+		if err != nil || len(r.Moves) == 0 {
+			return fmt.Errorf(tr("消費時間が不正です: %s"), stmt)
+		}
+		r.Times[len(r.Times)-1] = time.Duration(sec) * time.Second
+
+	case stmt[0] == '%':
+		r.End = stmt
+
+	default:
+		return fmt.Errorf(tr("解釈できない行です: %s"), stmt)
+	}
+	return nil
+}
+
+// 開始局面の行（PI, P1〜P5, P+, P-）を読み込み
+func readCSAPosition(stmt string, b *Board) error {
+	switch {
+	case stmt == "PI":
+		// 変則ルールの指定は残す
+		initial := b.rules().Setup()
+		b.Cells, b.FirstHand, b.SecondHand = initial.Cells, initial.FirstHand, initial.SecondHand
+
+	case len(stmt) >= 2 && stmt[1] >= '1' && int(stmt[1]-'0') <= b.Size():
+		row := int(stmt[1] - '1')
+		cells := stmt[2:]
+		if width := 3 * b.Size(); len(cells) < width {
+			cells += strings.Repeat(" ", width-len(cells))
+		}
+		for col := 0; col < b.Size(); col++ {
+			cell := cells[col*3 : col*3+3]
+			if strings.TrimSpace(cell) == "*" {
+				b.Cells[row][col] = Piece{Empty, None}
+				continue
+			}
+			pType, ok := csaPieceType(cell[1:])
+			if !ok || (cell[0] != '+' && cell[0] != '-') {
+				return fmt.Errorf(tr("駒の表記が不正です: %s"), cell)
+			}
+			owner := First
+			if cell[0] == '-' {
+				owner = Second
+			}
+			b.Cells[row][col] = Piece{pType, owner}
+		}
+
+	case strings.HasPrefix(stmt, "P+") || strings.HasPrefix(stmt, "P-"):
+		owner := First
+		hand := &b.FirstHand
+		if stmt[1] == '-' {
+			owner = Second
+			hand = &b.SecondHand
+		}
+		body := stmt[2:]
+		if len(body)%4 != 0 {
+			return fmt.Errorf(tr("駒の配置が不正です: %s"), stmt)
+		}
+		for i := 0; i < len(body); i += 4 {
+			sq, code := body[i:i+2], body[i+2:i+4]
+			pType, ok := csaPieceType(code)
+			if !ok {
+				return fmt.Errorf(tr("駒の種類が不正です: %s"), code)
+			}
+			if sq == "00" {
+				if pType != baseType(pType) || pType == King {
+					return fmt.Errorf(tr("持ち駒にできない駒です: %s"), code)
+				}
+				*hand = append(*hand, pType)
+				continue
+			}
+			row, col, ok := b.parseCSASquare(sq)
+			if !ok {
+				return fmt.Errorf(tr("座標が不正です: %s"), sq)
+			}
+			b.Cells[row][col] = Piece{pType, owner}
+		}
+
+	default:
+		return fmt.Errorf(tr("解釈できない行です: %s"), stmt)
+	}
+	return nil
+}
+
+// 棋譜ファイルを読み込み
+func loadRecord(path string) (*Record, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if isKIFFile(path) {
+		return ReadKIF(f)
+	}
+	return ReadCSA(f)
+}
+
+// 棋譜ファイルに保存（拡張子が .kif ならKIF形式、それ以外はCSA形式）
+func saveRecord(path string, r *Record) error {
+	if isKIFFile(path) {
+		return writeFile(path, r.WriteKIF)
+	}
+	return writeFile(path, r.WriteCSA)
+}
+
+// ファイルを作ってwriteで書き込む
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// 指し手の検証で返されるエラー（メッセージは日本語の原文。表示するときに訳す）
+var (
+	ErrOutOfBoard   = errors.New("盤の外です")
+	ErrNoPiece      = errors.New("移動元に駒がありません")
+	ErrNotYourPiece = errors.New("自分の駒ではありません")
+	ErrNotInHand    = errors.New("その駒は持っていません")
+	ErrCannotMove   = errors.New("その駒はそこへ動けません")
+
+	ErrNifu              = errors.New("二歩です")
+	ErrDropOnOccupied    = errors.New("駒のあるマスには打てません")
+	ErrLeavesKingInCheck = errors.New("玉が取られる手です")
+	ErrDeadPiece         = errors.New("行き所のない駒になります")
+	ErrUchifuzume        = errors.New("打ち歩詰めです")
+	ErrNoDrops           = errors.New("持ち駒なしのルールでは駒を打てません")
+)
+
+// 指せない手のエラー
+type IllegalMoveError struct {
+	Move Move
+	Err  error // 理由（上のエラーのいずれか）
+}
+
+func (e *IllegalMoveError) Error() string {
+	return fmt.Sprintf(tr("指せない手です: %s"), tr(e.Err.Error()))
+}
+
+func (e *IllegalMoveError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// 評価関数のパラメータ
+type EvalParams struct {
+	PieceValues map[string]int `json:"piece_values"` // 駒の価値（キーはCSA形式の駒の表記）
+	HandPercent int            `json:"hand_percent"` // 持ち駒の価値（盤上の駒に対する割合、%）
+
+	values [PromotedPawn + 1]int // 駒の種類で引けるようにした駒の価値
+}
+
+// 既定の評価パラメータ
+func DefaultEvalParams() *EvalParams {
+	p := &EvalParams{
+		PieceValues: map[string]int{
+			"OU": 10000,
+			"KI": 600,
+			"GI": 500,
+			"KA": 800,
+			"HI": 900,
+			"FU": 100,
+			"NG": 600,
+			"UM": 1000,
+			"RY": 1100,
+			"TO": 600,
+		},
+		HandPercent: 80,
+	}
+	p.resolve()
+	return p
+}
+
+// 現在の評価パラメータ
+var evalParams = DefaultEvalParams()
+
+// 駒の表記を検証して駒の種類ごとの価値を求める
+func (p *EvalParams) resolve() error {
+	for code, v := range p.PieceValues {
+		pType, ok := csaPieceType(code)
+		if !ok {
+			return fmt.Errorf(tr("駒の種類が不正です: %s"), code)
+		}
+		p.values[pType] = v
+	}
+	if p.HandPercent < 0 {
+		return fmt.Errorf(tr("持ち駒の割合が不正です: %d"), p.HandPercent)
+	}
+	return nil
+}
+
+// 駒の価値
+func (p *EvalParams) pieceValue(pType PieceType) int {
+	return p.values[pType]
+}
+
+// 持ち駒の価値
+func (p *EvalParams) handValue(pType PieceType) int {
+	return p.values[pType] * p.HandPercent / 100
+}
+
+// JSONファイルから評価パラメータを読み込み（書かれていない項目は既定値のまま）
+func loadEvalParams(path string) (*EvalParams, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := DefaultEvalParams()
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(p); err != nil {
+		return nil, err
+	}
+	if err := p.resolve(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
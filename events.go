@@ -0,0 +1,167 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// 指し手のイベント
+type MoveEvent struct {
+	Player   Player // 指した側
+	Move     Move
+	Position Position // 指した後の局面
+}
+
+// 駒取りのイベント
+type CaptureEvent struct {
+	Player   Player // 取った側
+	Move     Move
+	Captured Piece // 取られた駒（成り駒のまま）
+}
+
+// 王手のイベント
+type CheckEvent struct {
+	Player   Player // 王手をかけられた側
+	Position Position
+}
+
+// 終局のイベント
+type GameOverEvent struct {
+	Result   Result
+	Position Position
+}
+
+// 時計のイベント（手番中に1秒ごとに通知される）
+type ClockTickEvent struct {
+	Player  Player        // 手番の側
+	Elapsed time.Duration // この手の経過時間
+}
+
+// イベントの購読者
+type gameHooks struct {
+	mu        sync.Mutex
+	move      []func(MoveEvent)
+	capture   []func(CaptureEvent)
+	check     []func(CheckEvent)
+	gameOver  []func(GameOverEvent)
+	clockTick []func(ClockTickEvent)
+}
+
+// 指し手のたびに呼ばれる関数を登録
+func (g *Game) OnMove(f func(MoveEvent)) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	g.hooks.move = append(g.hooks.move, f)
+}
+
+// 駒を取ったときに呼ばれる関数を登録
+func (g *Game) OnCapture(f func(CaptureEvent)) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	g.hooks.capture = append(g.hooks.capture, f)
+}
+
+// 王手がかかったときに呼ばれる関数を登録
+func (g *Game) OnCheck(f func(CheckEvent)) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	g.hooks.check = append(g.hooks.check, f)
+}
+
+// 終局時に呼ばれる関数を登録
+func (g *Game) OnGameOver(f func(GameOverEvent)) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	g.hooks.gameOver = append(g.hooks.gameOver, f)
+}
+
+// 手番中に1秒ごとに呼ばれる関数を登録（ゲームループとは別のゴルーチンから呼ばれる）
+func (g *Game) OnClockTick(f func(ClockTickEvent)) {
+	g.hooks.mu.Lock()
+	defer g.hooks.mu.Unlock()
+	g.hooks.clockTick = append(g.hooks.clockTick, f)
+}
+
+// 購読者の一覧をコピーして取得（通知中の登録でロックが競合しないようにする）
+func snapshot[T any](h *gameHooks, list *[]func(T)) []func(T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]func(T){}, *list...)
+}
+
+func (g *Game) emitMove(e MoveEvent) {
+	for _, f := range snapshot(&g.hooks, &g.hooks.move) {
+		f(e)
+	}
+}
+
+func (g *Game) emitCapture(e CaptureEvent) {
+	for _, f := range snapshot(&g.hooks, &g.hooks.capture) {
+		f(e)
+	}
+}
+
+func (g *Game) emitCheck(e CheckEvent) {
+	for _, f := range snapshot(&g.hooks, &g.hooks.check) {
+		f(e)
+	}
+}
+
+func (g *Game) emitGameOver(e GameOverEvent) {
+	for _, f := range snapshot(&g.hooks, &g.hooks.gameOver) {
+		f(e)
+	}
+}
+
+func (g *Game) emitClockTick(e ClockTickEvent) {
+	for _, f := range snapshot(&g.hooks, &g.hooks.clockTick) {
+		f(e)
+	}
+}
+
+// 手番中の時計の通知を開始（返り値の関数で停止する）
+// 通知は g.now のタイマーで手番の開始から1秒ごとに鳴らす。停止の関数は、通知中の購読者の処理が終わるのを待って戻り、
+// その後は通知しない（指し手のイベントより後に時計のイベントが届くことはない）。
+func (g *Game) startClockTicks(player Player, start time.Time) func() {
+	var (
+		mu      sync.Mutex
+		stopped bool
+		timer   Timer
+		running sync.WaitGroup // 通知中の購読者の処理
+		ticks   time.Duration
+		tick    func()
+	)
+	schedule := func() {
+		ticks += time.Second
+		timer = g.now.AfterFunc(start.Add(ticks).Sub(g.now.Now()), tick)
+	}
+	tick = func() {
+		mu.Lock()
+		if stopped {
+			mu.Unlock()
+			return
+		}
+		running.Add(1)
+		mu.Unlock()
+
+		g.emitClockTick(ClockTickEvent{player, g.now.Now().Sub(start)})
+		running.Done()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if !stopped {
+			schedule()
+		}
+	}
+
+	mu.Lock()
+	schedule()
+	mu.Unlock()
+	return func() {
+		mu.Lock()
+		stopped = true
+		timer.Stop()
+		mu.Unlock()
+		running.Wait()
+	}
+}
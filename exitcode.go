@@ -0,0 +1,29 @@
+package main
+
+// 終了コード（-moves・AI同士の対局・move サブコマンドでは対局結果を表す。人間が指す対局は結果によらず0で終わる）
+// サブコマンドも引数の誤りはexitUsage、エラーはexitErrorで終える（0〜2の対局結果と区別する）。
+const (
+	exitDraw       = 0 // 引き分け
+	exitSenteWin   = 1 // 先手の勝ち
+	exitGoteWin    = 2 // 後手の勝ち
+	exitUnfinished = 3 // 終局しなかった（台本の指し手を指し終えた、中断した）
+	exitBadScript  = 4 // 台本に指せない手があった
+	exitUsage      = 5 // 引数の誤り
+	exitError      = 6 // ファイルを読めない、相手と接続できないなど
+)
+
+// 対局させた結果の終了コード
+func (g *Game) exitCode() int {
+	if g.scriptFailed {
+		return exitBadScript
+	}
+	switch g.Result.Outcome {
+	case SenteWin:
+		return exitSenteWin
+	case GoteWin:
+		return exitGoteWin
+	case Draw:
+		return exitDraw
+	}
+	return exitUnfinished
+}
@@ -0,0 +1,666 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 時刻の取得元とタイマー（テストなどで差し替えられるようにする）
+type TimeSource interface {
+	Now() time.Time
+	// d経過したらfを別のゴルーチンで呼ぶ
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// 止められるタイマー（*time.Timer と同じ）
+type Timer interface {
+	Stop() bool
+}
+
+// 実際の時刻
+type systemTime struct{}
+
+func (systemTime) Now() time.Time {
+	return time.Now()
+}
+
+func (systemTime) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// 対局（入出力を差し替えられるゲームループ）
+// 対局者名と指し手の履歴は Record が持つ。
+type Game struct {
+	Board       *Board
+	Record      *Record
+	AIPlayer    Player      // AIが指す側（人間同士・AI同士ならNone）
+	SelfPlay    bool        // AI同士で対局する
+	SaveFile    string      // 終局後に棋譜を保存するファイル（空なら保存しない）
+	Clock       Clock       // 対局時計（nilなら時間制限なし）
+	Result      Result      // 対局結果（対局中はUndecided）
+	Contempt    int         // AIが千日手を嫌う度合い（正なら避け、負なら歓迎する）
+	EngineLog   io.Writer   // AIの探索の記録の出力先（nilなら記録しない）
+	Training    bool        // 練習モード（人間が指すたびに最善手と比べて表示する）
+	Coach       bool        // コーチモード（人間が指す前に取られそうな駒と相手の狙いを表示する）
+	Flip        bool        // 人間が後手のとき、盤面と座標を後手側から見た向きにする
+	Remote      *remotePeer // ソケットでつないだ対局相手（nilなら同じ端末で対局する）
+	Quiet       bool        // 指し手と結果だけを表示する
+	Verbose     bool        // AIの探索の詳細と消費時間を表示する
+	NoBoard     bool        // 盤面を表示しない
+	Script      []string    // 入力の代わりに順に指す手（nilなら入力から読む）
+	Nodes       int         // AIが1手に探索する局面の数の上限（0なら制限しない）
+	Threads     int         // AIが初手を分担して読むゴルーチンの数（1以下なら分担しない）
+	Book        *Book       // AIが使う定跡（nilなら使わない）
+	Resign      int         // AIが投了する評価値（AIから見て -Resign 以下がResignMoves手続いたら投了。0なら投了しない）
+	ResignMoves int         // AIが投了するまでに見込みのない評価値が続く手数
+
+	base       context.Context         // 対局全体のコンテキスト（中断でキャンセルされる）
+	stop       context.CancelCauseFunc // 対局を中断する
+	turnMu     sync.Mutex
+	cancelTurn context.CancelCauseFunc // 手番の探索・入力待ちを打ち切る
+
+	in       io.Reader
+	lines    chan string // 入力の各行（読み込み用のゴルーチンから届く）
+	readOnce sync.Once
+	out      io.Writer
+	now      TimeSource
+	hooks    gameHooks
+	jsonOut  *json.Encoder // 行ごとのJSONの出力先（nilならJSONで出力しない）
+
+	modeSelected bool                               // 先後を選択済みか（再戦では選び直さない）
+	players      [3]string                          // 手番ごとの対局者名
+	clockLimits  [3]time.Duration                   // 手番ごとの持ち時間（再戦で時計を作り直すときに使う）
+	session      sessionScore                       // 連続対局の通算成績
+	games        int                                // これまでに終えた対局の数
+	saveBase     string                             // 1局目の棋譜の保存先
+	saveAs       func(path string, r *Record) error // 棋譜の保存のしかた（nilならsaveRecord）
+	remoteSide   Player                             // ソケットでつないだ相手の手番
+	scriptFailed bool                               // 台本に指せない手があった
+	engines      [3]*EngineConfig                   // 手番ごとのAIの設定（nilなら共通の設定）
+	bot          Bot                                // 人間の代わりに指すボット（nilならいない）
+	hopeless     [3]int                             // 手番ごとの、AIの評価値が投了の基準を下回り続けている手数
+	botSide      Player                             // ボットの手番
+}
+
+// 対局を作成
+func NewGame(in io.Reader, out io.Writer, now TimeSource) *Game {
+	board := NewBoard()
+	base, stop := context.WithCancelCause(context.Background())
+	return &Game{
+		Board:    board,
+		Record:   NewRecord(board),
+		AIPlayer: Second,
+		base:     base,
+		stop:     stop,
+		in:       in,
+		out:      out,
+		now:      now,
+	}
+}
+
+// 開始局面を設定
+func (g *Game) SetPosition(board *Board) {
+	g.Board = board
+	g.Record = NewRecord(board)
+}
+
+// 棋譜の続きから対局する
+func (g *Game) Resume(record *Record) {
+	g.Record = record
+	g.Board = record.Position(len(record.Moves))
+}
+
+// 入力の読み込みを開始（時間切れで入力待ちを打ち切れるように別のゴルーチンで読む）
+func (g *Game) startReader() {
+	g.readOnce.Do(func() {
+		g.lines = make(chan string)
+		go func() {
+			scanner := bufio.NewScanner(g.in)
+			for scanner.Scan() {
+				g.lines <- scanner.Text()
+			}
+			close(g.lines)
+		}()
+	})
+}
+
+// 1行読み込み
+func (g *Game) readLine() string {
+	line, _ := g.readLineContext(g.base)
+	return line
+}
+
+// 1行読み込み（ctxがキャンセルされるか入力が終わるとfalseを返す）
+func (g *Game) readLineContext(ctx context.Context) (string, bool) {
+	g.startReader()
+	select {
+	case line, ok := <-g.lines:
+		return line, ok
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+// 対局者名を設定
+func (g *Game) setNames() {
+	g.Record.FirstName, g.Record.SecondName = g.players[First], g.players[Second]
+}
+
+// AIが指す手番か
+func (g *Game) isAI(player Player) bool {
+	return g.SelfPlay || player == g.AIPlayer
+}
+
+// メインゲームループ
+func (g *Game) Run() {
+	if !g.modeSelected {
+		g.SetMode(ModeHumanFirst)
+	}
+	if g.Clock != nil {
+		g.Clock.OnFlag(func(Player) {
+			g.endTurn(errTimeUp)
+		})
+	}
+
+	g.jsonStart()
+	board := g.Board
+	turnStart := g.now.Now()
+	for {
+		g.display()
+
+		if !g.Result.Decided() {
+			g.Result = g.judge()
+		}
+		if g.Result.Decided() {
+			g.finish()
+			break
+		}
+
+		if board.CurrentTurn == First {
+			fmt.Fprintln(g.chat(), "\n"+tr("先手の番です"))
+		} else {
+			fmt.Fprintln(g.chat(), "\n"+tr("後手の番です"))
+		}
+		g.printClocks()
+
+		var move *Move
+		var score *int
+		player := board.CurrentTurn
+		ctx, cancel := g.startTurn(player)
+		stopTicks := g.startClockTicks(player, turnStart)
+
+		if g.bot != nil && player == g.botSide {
+			move = g.botMove(ctx)
+		} else if g.isAI(player) {
+			fmt.Fprintln(g.chat(), tr("AIが考えています..."))
+			result := g.searchAIMove(ctx)
+			move, score = result.Move, &result.Score
+			if move != nil && g.shouldResign(player, result.Score) {
+				g.printReply(jsonEvent{Type: "resign", Player: jsonPlayers[player]}, func(w io.Writer) {
+					fmt.Fprintln(w, tr("AIが投了しました"))
+				})
+				g.Result = winResult(player.Opponent(), ReasonResign)
+				move = nil
+			}
+			if move != nil {
+				g.printAIMove(move)
+				g.jsonAIMove(board, result)
+			}
+		} else if g.Remote != nil && player == g.remoteSide {
+			fmt.Fprintln(g.chat(), tr("相手の手を待っています..."))
+			move = g.readRemoteMove(ctx)
+			if move != nil {
+				fmt.Fprintf(g.chat(), tr("相手: %s")+"\n", g.moveText(move))
+			}
+		} else {
+			if g.Coach {
+				g.printReply(jsonEvent{Type: "coach", Player: jsonPlayers[player]}, g.printCoach)
+			}
+			if g.Script != nil {
+				move = g.scriptMove()
+			} else {
+				move = g.readHumanMove(ctx)
+			}
+		}
+		stopTicks()
+		if g.Clock != nil {
+			g.Clock.Stop()
+		}
+		cause := context.Cause(ctx)
+		cancel(nil)
+
+		if cause == errInterrupted {
+			g.suspend()
+			return
+		}
+		if cause == errQuit || cause == errEndOfInput {
+			fmt.Fprintln(g.out, "\n"+tr("対局をやめました"))
+			g.sendRemote("%CHUDAN")
+			g.save()
+			reason := "quit"
+			if cause == errEndOfInput {
+				reason = "eof"
+			}
+			g.jsonQuit(reason)
+			return
+		}
+		if cause == errEndOfScript || cause == errBadScript {
+			reason := "bad_script"
+			if cause == errEndOfScript {
+				fmt.Fprintln(g.out, "\n"+tr("台本の指し手を指し終えました"))
+				reason = "end_of_script"
+			}
+			g.save()
+			g.jsonQuit(reason)
+			return
+		}
+
+		// 持ち時間を使い切ったら、探索や入力待ちの途中でもその場で負け
+		if cause == errTimeUp {
+			fmt.Fprintln(g.out, "\n"+tr("時間切れです"))
+			g.Result = winResult(player.Opponent(), ReasonTimeout)
+			continue
+		}
+
+		if move != nil {
+			now := g.now.Now()
+			elapsed := now.Sub(turnStart)
+			before := board.Clone()
+			if err := g.play(*move); err != nil {
+				g.printError(errorCode(err, "illegal_move"), err)
+				continue
+			}
+			g.printPlayed(player, move, elapsed)
+			if g.Training && !g.isAI(player) {
+				g.printTraining(before, *move)
+			}
+			if player != g.remoteSide {
+				g.sendRemote(csaMove(before, *move))
+			}
+			g.Record.Add(*move, elapsed)
+			g.Record.Notes[len(g.Record.Notes)-1].Score = score
+			g.jsonMove(before, *move, elapsed)
+			turnStart = now
+		}
+	}
+}
+
+// 練習モードの解析の探索深度
+const trainingDepth = 4
+
+// 時間切れを表すキャンセルの理由
+var errTimeUp = errors.New("時間切れ")
+
+// 手番を開始して時計を動かす（返り値のコンテキストは時間切れでキャンセルされる）
+func (g *Game) startTurn(player Player) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(g.base)
+	g.turnMu.Lock()
+	g.cancelTurn = cancel
+	g.turnMu.Unlock()
+	if g.Clock != nil {
+		g.Clock.Start(player)
+	}
+	return ctx, cancel
+}
+
+// 手番の探索・入力待ちを打ち切る（別のゴルーチンから呼べる）
+func (g *Game) endTurn(cause error) {
+	g.turnMu.Lock()
+	defer g.turnMu.Unlock()
+	if g.cancelTurn != nil {
+		g.cancelTurn(cause)
+	}
+}
+
+// 局面から勝敗を判定
+func (g *Game) judge() Result {
+	if c, winner := g.Board.winCondition(); c != nil {
+		return winResult(winner, c.Reason())
+	}
+	if gameOver, winner := g.Board.IsGameOver(); gameOver {
+		return winResult(winner, ReasonMate)
+	}
+	if g.IsDraw() {
+		return Result{Draw, ReasonRepetition}
+	}
+	return Result{}
+}
+
+// 終局の処理
+func (g *Game) finish() {
+	if c, winner := g.Board.winCondition(); c != nil && g.Result.Reason == c.Reason() {
+		fmt.Fprintln(g.out, "\n"+c.Announce(winner))
+	}
+	fmt.Fprintln(g.out, "\n"+g.Result.String())
+	g.Record.End = g.Result.csaEnd()
+	g.save()
+	g.jsonResult()
+	g.emitGameOver(GameOverEvent{g.Result, NewPosition(g.Board)})
+}
+
+// 切れ負けの時計を使う（棋譜の続きから対局する場合は消費時間を差し引く）
+func (g *Game) UseClock(limit time.Duration) {
+	g.useClocks([3]time.Duration{First: limit, Second: limit})
+}
+
+// 手番ごとの持ち時間で対局時計を使う
+func (g *Game) useClocks(limits [3]time.Duration) {
+	g.clockLimits = limits
+	clock := NewSuddenDeathClock(limits[First], g.now)
+	used := g.Record.usedTimes()
+	clock.Set(First, limits[First]-used[First])
+	clock.Set(Second, limits[Second]-used[Second])
+	g.Clock = clock
+}
+
+// 残り時間を表示（時間制限がなければ消費時間の累計を表示）
+func (g *Game) printClocks() {
+	if g.Clock == nil {
+		if len(g.Record.Moves) > 0 {
+			used := g.Record.usedTimes()
+			fmt.Fprintf(g.chat(), tr("消費時間 先手 %s / 後手 %s")+"\n", formatClock(used[First]), formatClock(used[Second]))
+		}
+		return
+	}
+	fmt.Fprintf(g.chat(), tr("残り時間 先手 %s / 後手 %s")+"\n",
+		formatClock(g.Clock.Remaining(First)), formatClock(g.Clock.Remaining(Second)))
+}
+
+// 時間を「分:秒」で表示
+func formatClock(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	sec := int(d.Seconds())
+	return fmt.Sprintf("%d:%02d", sec/60, sec%60)
+}
+
+// 指し手を盤面に反映してイベントを通知
+func (g *Game) play(move Move) error {
+	board := g.Board
+	player := board.CurrentTurn
+	var captured Piece
+	if !move.IsDrop {
+		captured = board.Cells[move.ToRow][move.ToCol]
+	}
+
+	if err := board.MakeMove(move); err != nil {
+		return err
+	}
+	pos := NewPosition(board)
+
+	g.emitMove(MoveEvent{player, move, pos})
+	if captured.Owner != None {
+		g.emitCapture(CaptureEvent{player, move, captured})
+	}
+	if board.InCheck() {
+		g.emitCheck(CheckEvent{board.CurrentTurn, pos})
+	}
+	return nil
+}
+
+// AIの手を探索（千日手を検出できるように対局中の局面を渡す）
+func (g *Game) searchAIMove(ctx context.Context) SearchResult {
+	opts := SearchOptions{
+		Depth:    defaultDepth,
+		Contempt: g.Contempt,
+		history:  g.positionCounts(),
+		Ply:      len(g.Record.Moves),
+		Log:      g.EngineLog,
+		Nodes:    g.Nodes,
+		Threads:  g.Threads,
+		Now:      g.now,
+	}
+	if opts.Log == nil && g.Verbose {
+		opts.Log = g.out
+	}
+	if g.Clock != nil {
+		opts.Remaining = g.Clock.Remaining(g.Board.CurrentTurn)
+	}
+	g.engines[g.Board.CurrentTurn].apply(&opts)
+	g.Record.Depths[g.Board.CurrentTurn] = opts.Depth
+	bk := g.Book
+	if e := g.engines[g.Board.CurrentTurn]; e != nil && e.book != nil {
+		bk = e.book
+	}
+	if bk != nil {
+		if m, ok := bk.Choose(g.Board); ok {
+			fmt.Fprintln(g.chat(), tr("AI: 定跡の手を指します"))
+			g.emitJSON(jsonEvent{Type: "book", Player: jsonPlayers[g.Board.CurrentTurn], Move: csaMove(g.Board, m)})
+			return SearchResult{Move: &m, Score: g.Board.Evaluate()}
+		}
+	}
+	result := g.Board.Analyze(ctx, opts)
+	if ctx.Err() != nil {
+		return SearchResult{}
+	}
+	return result
+}
+
+// AIが投了するか（AIから見た評価値が基準を下回った手数を数え、ResignMoves手続いたら投了する）
+func (g *Game) shouldResign(player Player, score int) bool {
+	if g.Resign <= 0 {
+		return false
+	}
+	if player == Second {
+		score = -score
+	}
+	if score > -g.Resign {
+		g.hopeless[player] = 0
+		return false
+	}
+	g.hopeless[player]++
+	return g.hopeless[player] >= max(g.ResignMoves, 1)
+}
+
+// AIの指し手を表示
+func (g *Game) printAIMove(move *Move) {
+	fmt.Fprintf(g.chat(), "AI: %s\n", g.moveText(move))
+}
+
+// 指し手の表示（例: 2一から4三へ、角を2三に打つ）
+func moveText(move *Move) string {
+	if move.IsDrop {
+		return fmt.Sprintf(tr("%sを%d%sに打つ"),
+			currentTheme.pieceName(move.DropPiece),
+			move.ToCol+1,
+			rankNames[move.ToRow])
+	}
+	text := fmt.Sprintf(tr("%d%sから%d%sへ"),
+		move.FromCol+1,
+		rankNames[move.FromRow],
+		move.ToCol+1,
+		rankNames[move.ToRow])
+	if move.Promote {
+		text += tr("（成）")
+	}
+	return text
+}
+
+// 練習モード: 人間の指した手を最善手と比べて表示
+func (g *Game) printTraining(before *Board, move Move) {
+	a := analyzeMove(before, move, trainingDepth)
+	if a.BestMove == nil {
+		return
+	}
+	player := before.CurrentTurn
+	best, played := a.Best, a.Played
+	if player == Second {
+		best, played = -best, -played
+	}
+	e := jsonEvent{
+		Type:   "training",
+		Player: jsonPlayers[player],
+		Move:   csaMove(before, *a.BestMove),
+		Score:  &a.Best,
+		Played: &a.Played,
+	}
+	g.printReply(e, func(w io.Writer) {
+		loss := a.loss(player)
+		if loss <= 0 {
+			fmt.Fprintf(w, tr("検討: 最善手です（評価値 %d）")+"\n", played)
+			return
+		}
+		fmt.Fprintf(w, tr("検討: 最善手は %s（評価値 %d）、指した手は評価値 %d（%d 損）"),
+			g.moveText(a.BestMove), best, played, loss)
+		if mark := lossMark(loss); mark != "" {
+			fmt.Fprintf(w, " %s", mark)
+		}
+		fmt.Fprintln(w)
+	})
+}
+
+// 人間の入力（指し手として受け付けなかった場合はnil）
+func (g *Game) readHumanMove(ctx context.Context) *Move {
+	board := g.Board
+	w := g.chat()
+	fmt.Fprintln(w, tr("移動: 5133 のように入力（51から33へ。4142+ で成る、4142= で成らない）"))
+	fmt.Fprintln(w, tr("持ち駒: p53 のように入力（p=歩,s=銀,g=金,b=角,r=飛を53に打つ）"))
+	fmt.Fprintln(w, tr("コマンド: bod（盤面図を表示）, hand（打てる持ち駒を表示）, show 53（53の駒の動ける先を表示）, threats（相手の利きと狙いを表示）, note <コメント>（直前の手にコメント）, resign（投了）, quit（対局をやめる）"))
+	if g.Remote != nil && g.Remote.caps["chat"] {
+		fmt.Fprintln(w, tr("チャット: say <メッセージ>（相手にメッセージを送る）"))
+	}
+	fmt.Fprint(w, tr("入力: "))
+	g.jsonPrompt("move")
+
+	input, ok := g.readLineContext(ctx)
+	if !ok {
+		// 入力が終わったら（パイプの入力を使い切ったなど）対局をやめる
+		if ctx.Err() == nil {
+			fmt.Fprintln(g.out, "\n"+tr("入力が終わりました"))
+			g.stop(errEndOfInput)
+		}
+		return nil
+	}
+
+	if square, ok := strings.CutPrefix(strings.TrimSpace(input), "show "); ok {
+		g.printReply(jsonEvent{Type: "show", Square: strings.TrimSpace(square)}, func(w io.Writer) {
+			g.printPieceMoves(w, square)
+		})
+		return nil
+	}
+	if text, ok := strings.CutPrefix(strings.TrimSpace(input), "say "); ok {
+		g.sendChat(strings.TrimSpace(text))
+		return nil
+	}
+	if text, ok := strings.CutPrefix(strings.TrimSpace(input), "note "); ok {
+		text = strings.TrimSpace(text)
+		if !g.Record.AddComment(text) {
+			g.printError("no_move_to_comment", tr("コメントを付ける手がありません"))
+			return nil
+		}
+		g.printReply(jsonEvent{Type: "note", Ply: len(g.Record.Moves), Message: text}, func(w io.Writer) {
+			fmt.Fprintln(w, tr("コメントを付けました"))
+		})
+		return nil
+	}
+
+	switch strings.TrimSpace(input) {
+	case "bod":
+		fmt.Fprintln(g.out)
+		g.printReply(jsonEvent{Type: "bod"}, func(w io.Writer) {
+			board.WriteBOD(w)
+		})
+		return nil
+	case "threats":
+		g.printReply(jsonEvent{Type: "threats", Player: jsonPlayers[board.CurrentTurn]}, g.printThreats)
+		return nil
+	case "hand":
+		e := jsonEvent{Type: "hand", Player: jsonPlayers[board.CurrentTurn]}
+		for _, p := range board.handOf(board.CurrentTurn) {
+			e.Pieces = append(e.Pieces, csaPieces[p])
+		}
+		g.printReply(e, g.printHand)
+		return nil
+	case "quit", "終了":
+		// 保存しない対局は、やめる前に確かめる
+		if g.SaveFile == "" && len(g.Record.Moves) > 0 {
+			fmt.Fprint(g.chat(), tr("棋譜を保存せずに対局をやめますか？ (y/n): "))
+			g.jsonPrompt("quit")
+			if answer, _ := g.readLineContext(ctx); answer != "y" {
+				return nil
+			}
+		}
+		g.Quit()
+		return nil
+	case "resign", "投了":
+		g.Result = winResult(board.CurrentTurn.Opponent(), ReasonResign)
+		g.sendRemote("%TORYO")
+		return nil
+	}
+
+	move := parseInput(input, board)
+	if move == nil {
+		if !g.explainDropInput(input) {
+			g.printError("invalid_input", tr("無効な入力です"))
+		}
+		return nil
+	}
+	if g.flippedView() {
+		*move = board.flipMove(*move)
+	}
+
+	// 成・不成を指定せずに成れる手を入力したら、成らない手も指せるときだけ尋ねる
+	if _, _, explicit := cutPromotion(strings.TrimSpace(input)); !explicit && canChoosePromote(board, move) {
+		promoted := *move
+		promoted.Promote = true
+		if board.ValidateMove(promoted) == nil {
+			if board.ValidateMove(*move) != nil {
+				move.Promote = true // 成らないと行き所のない駒になる
+			} else {
+				fmt.Fprint(g.chat(), tr("成りますか？ (y/n): "))
+				g.jsonPrompt("promote")
+				if answer, _ := g.readLineContext(ctx); answer == "y" {
+					move.Promote = true
+				}
+			}
+		}
+	}
+
+	// 合法手チェック
+	err := board.ValidateMove(*move)
+	if err == nil {
+		return move
+	}
+
+	if errors.Is(err, ErrNotInHand) {
+		g.printError(jsonErrorCodes[ErrNotInHand], fmt.Sprintf(tr("%sを持っていません（打てる持ち駒: %s）"),
+			currentTheme.pieceName(move.DropPiece), board.droppableText()))
+		return nil
+	}
+	g.printError(errorCode(err, "illegal_move"), fmt.Sprintf(tr("その手は指せません（%s）"), illegalReason(err)))
+	return nil
+}
+
+// 指せない理由
+func illegalReason(err error) string {
+	var ime *IllegalMoveError
+	if errors.As(err, &ime) {
+		return tr(ime.Err.Error())
+	}
+	return tr(err.Error())
+}
+
+// 棋譜を保存（保存できたらtrue）
+func (g *Game) save() bool {
+	if g.SaveFile == "" {
+		return false
+	}
+	save := saveRecord
+	if g.saveAs != nil {
+		save = g.saveAs
+	}
+	if err := save(g.SaveFile, g.Record); err != nil {
+		g.printError("save_failed", tr("棋譜を保存できません:"), err)
+		return false
+	}
+	fmt.Fprintln(g.out, tr("棋譜を保存しました:"), g.SaveFile)
+	return true
+}
@@ -0,0 +1,451 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// 手で進める時刻と、登録されたタイマーを手で鳴らせる時刻の取得元
+type fakeTime struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers chan *fakeTimer // AfterFuncで登録されたタイマー（登録した順）
+}
+
+func newFakeTime() *fakeTime {
+	return &fakeTime{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), timers: make(chan *fakeTimer, 16)}
+}
+
+func (f *fakeTime) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// 時刻をd進める（タイマーは鳴らさない）
+func (f *fakeTime) advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func (f *fakeTime) AfterFunc(d time.Duration, fn func()) Timer {
+	t := &fakeTimer{fn: fn}
+	f.timers <- t
+	return t
+}
+
+// 次に登録されるタイマー
+func (f *fakeTime) nextTimer(t *testing.T) *fakeTimer {
+	t.Helper()
+	select {
+	case timer := <-f.timers:
+		return timer
+	case <-time.After(5 * time.Second):
+		t.Fatal("タイマーが登録されません")
+		return nil
+	}
+}
+
+type fakeTimer struct {
+	fn func()
+}
+
+func (*fakeTimer) Stop() bool {
+	return true
+}
+
+// 登録された関数を呼ぶ（止めた後でも呼ぶ。止める直前に鳴ったタイマーの代わり）
+func (t *fakeTimer) fire() {
+	t.fn()
+}
+
+// 局面を読み込む（読めなければテストを止める）
+func mustSFEN(t *testing.T, sfen string) *Board {
+	t.Helper()
+	b, err := ParseSFEN(sfen)
+	if err != nil {
+		t.Fatalf("ParseSFEN(%q): %v", sfen, err)
+	}
+	return b
+}
+
+// 人間同士の対局を、入力を台本にして最後まで進める（bがnilなら初期局面から）
+func playScript(t *testing.T, b *Board, input string) (*Game, string) {
+	t.Helper()
+	var out bytes.Buffer
+	g := NewGame(strings.NewReader(input), &out, systemTime{})
+	if b != nil {
+		g.SetPosition(b)
+	}
+	g.SetMode(ModeHotSeat)
+	g.Run()
+	return g, out.String()
+}
+
+func TestGameMate(t *testing.T) {
+	g, _ := playScript(t, mustSFEN(t, "2k2/5/1G2R/5/KP3 b - 1"), "5351+\n")
+	if want := (Result{SenteWin, ReasonMate}); g.Result != want {
+		t.Errorf("Result = %v, want %v", g.Result, want)
+	}
+	if g.Record.End != "%TSUMI" || len(g.Record.Moves) != 1 {
+		t.Errorf("棋譜 = %d手 %s, want 1手 %%TSUMI", len(g.Record.Moves), g.Record.End)
+	}
+}
+
+func TestGameResign(t *testing.T) {
+	g, _ := playScript(t, nil, "1413\nresign\n")
+	if want := (Result{SenteWin, ReasonResign}); g.Result != want {
+		t.Errorf("Result = %v, want %v", g.Result, want)
+	}
+	if g.Record.End != "%TORYO" || len(g.Record.Moves) != 1 {
+		t.Errorf("棋譜 = %d手 %s, want 1手 %%TORYO", len(g.Record.Moves), g.Record.End)
+	}
+}
+
+func TestGameIllegalInput(t *testing.T) {
+	g, out := playScript(t, nil, "abc\n1412\n1413\nresign\n")
+	for _, want := range []string{"無効な入力です", "その手は指せません"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("出力に %q がありません", want)
+		}
+	}
+	// 指せない手は指さずに、同じ手番で入力を待つ
+	if len(g.Record.Moves) != 1 || g.Result != (Result{SenteWin, ReasonResign}) {
+		t.Errorf("棋譜 = %d手 %v, want 1手 後手の投了", len(g.Record.Moves), g.Result)
+	}
+}
+
+func TestGameEndOfInput(t *testing.T) {
+	g, out := playScript(t, nil, "1413\n")
+	if g.Result.Decided() {
+		t.Errorf("Result = %v, want 未決着", g.Result)
+	}
+	if !strings.Contains(out, "入力が終わりました") || !strings.Contains(out, "対局をやめました") {
+		t.Errorf("入力の終わりで対局をやめていません:\n%s", out)
+	}
+	if len(g.Record.Moves) != 1 {
+		t.Errorf("棋譜 = %d手, want 1手", len(g.Record.Moves))
+	}
+}
+
+func TestGameTimeUp(t *testing.T) {
+	now := newFakeTime()
+	in, w := io.Pipe()
+	defer w.Close()
+	g := NewGame(in, io.Discard, now)
+	g.SetMode(ModeHotSeat)
+	g.UseClock(time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		g.Run()
+		close(done)
+	}()
+	// 先手の手番で持ち時間を使い切る（時計のタイマーは時計の通知のタイマーより先に登録される）
+	now.nextTimer(t).fire()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("時間切れで対局が終わりません")
+	}
+	if want := (Result{GoteWin, ReasonTimeout}); g.Result != want {
+		t.Errorf("Result = %v, want %v", g.Result, want)
+	}
+	if g.Record.End != "%TIME_UP" {
+		t.Errorf("End = %s, want %%TIME_UP", g.Record.End)
+	}
+}
+
+func TestGameClockTicks(t *testing.T) {
+	now := newFakeTime()
+	in, w := io.Pipe()
+	defer w.Close()
+	g := NewGame(in, io.Discard, now)
+	g.SetMode(ModeHotSeat)
+	ticks := make(chan ClockTickEvent, 16)
+	g.OnClockTick(func(e ClockTickEvent) { ticks <- e })
+	moved := make(chan struct{})
+	g.OnMove(func(MoveEvent) { close(moved) })
+	go g.Run()
+
+	// 通知の経過時間は差し替えた時刻で測る
+	tick := now.nextTimer(t)
+	now.advance(time.Second)
+	tick.fire()
+	select {
+	case e := <-ticks:
+		if e.Player != First || e.Elapsed != time.Second {
+			t.Errorf("通知 = %+v, want 先手 1s", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("時計の通知が届きません")
+	}
+
+	// 手を指した後は、止める前に鳴ったタイマーがあっても通知しない
+	next := now.nextTimer(t)
+	io.WriteString(w, "1413\n")
+	<-moved
+	next.fire()
+	select {
+	case e := <-ticks:
+		t.Errorf("指した後に通知が届きました: %+v", e)
+	default:
+	}
+	g.Quit()
+}
+
+func TestPerft(t *testing.T) {
+	for depth, want := range []int{1: 14, 2: 181, 3: 2512, 4: 35401} {
+		if depth == 0 {
+			continue
+		}
+		if got := NewBoard().Perft(depth); got != want {
+			t.Errorf("Perft(%d) = %d, want %d", depth, got, want)
+		}
+	}
+}
+
+// 読み書きを確かめる局面（初期局面・成り駒・両者の持ち駒・後手番）
+var roundTripSFENs = []string{
+	"rbsgk/4p/5/P4/KGSBR b - 1",
+	"2k1+B/2B2/1G3/5/1K3 b - 1",
+	"5/k4/2+R2/1s3/3K1 b G2P 1",
+	"2k2/G4/S2p1/1K3/4+R w Bs 1",
+}
+
+func TestSFENRoundTrip(t *testing.T) {
+	for _, sfen := range roundTripSFENs {
+		if got := mustSFEN(t, sfen).SFEN(1); got != sfen {
+			t.Errorf("SFEN(ParseSFEN(%q)) = %q", sfen, got)
+		}
+	}
+}
+
+func TestBODRoundTrip(t *testing.T) {
+	for _, sfen := range roundTripSFENs {
+		var buf bytes.Buffer
+		if err := mustSFEN(t, sfen).WriteBOD(&buf); err != nil {
+			t.Fatalf("WriteBOD(%q): %v", sfen, err)
+		}
+		b, err := ReadBOD(&buf)
+		if err != nil {
+			t.Fatalf("ReadBOD(%q): %v\n%s", sfen, err, buf.String())
+		}
+		if got := b.SFEN(1); got != sfen {
+			t.Errorf("BODを読み直した局面 = %q, want %q", got, sfen)
+		}
+	}
+}
+
+func TestReadBoardSize(t *testing.T) {
+	// 盤の大きさは読み込んだ段の数で決まり、ルールの盤の大きさ（標準は5）と違えば読み込まない
+	if _, err := ParseSFEN("k2/3/2K b - 1"); err == nil {
+		t.Error("3段のSFENを読み込みました")
+	}
+	var buf bytes.Buffer
+	newEmptyBoard(3).WriteBOD(&buf)
+	if _, err := ReadBOD(&buf); err == nil {
+		t.Errorf("3段の盤面図を読み込みました:\n%s", buf.String())
+	}
+}
+
+func TestDisplayBoardSize(t *testing.T) {
+	defer func(t *Theme) { currentTheme = t }(currentTheme)
+	for _, name := range []string{"default", "minimal", "dense", "color"} {
+		currentTheme = themes[name]
+		for _, size := range []int{3, 5, 7} {
+			var buf bytes.Buffer
+			newEmptyBoard(size).Display(&buf)
+			out := buf.String()
+			if !strings.Contains(out, kifFiles[size-1]) || strings.Contains(out, kifFiles[size]) {
+				t.Errorf("%s: %d筋の盤の見出しが合いません:\n%s", name, size, out)
+			}
+			if size == themeFiles && !strings.Contains(out, currentTheme.Header+"\n") {
+				t.Errorf("%s: 5筋の盤でテーマの見出しのまま表示されません:\n%s", name, out)
+			}
+			if currentTheme.Top == "" {
+				continue
+			}
+			// 罫線は筋の数が多いほど長い（5筋ならテーマの罫線のまま）
+			top := []rune(strings.Split(out, "\n")[2])
+			base := []rune(currentTheme.Top)
+			if size < themeFiles && len(top) >= len(base) || size == themeFiles && string(top) != string(base) || size > themeFiles && len(top) <= len(base) {
+				t.Errorf("%s: %d筋の盤の罫線 %q の長さが合いません", name, size, string(top))
+			}
+		}
+	}
+}
+
+// 初期局面から、毎回最初の合法手を指した棋譜
+func testRecord(plies int) *Record {
+	r := NewRecord(NewBoard())
+	b := r.Initial.Clone()
+	for i := 0; i < plies; i++ {
+		m := b.GetAllLegalMoves()[0]
+		b.ApplyLegal(m)
+		r.Add(m, time.Duration(i+1)*time.Second)
+	}
+	r.End = "%TORYO"
+	return r
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	formats := []struct {
+		name  string
+		write func(*Record, io.Writer) error
+		read  func(io.Reader) (*Record, error)
+	}{
+		{"CSA", (*Record).WriteCSA, ReadCSA},
+		{"KIF", (*Record).WriteKIF, ReadKIF},
+	}
+	for _, f := range formats {
+		t.Run(f.name, func(t *testing.T) {
+			r := testRecord(8)
+			var buf bytes.Buffer
+			if err := f.write(r, &buf); err != nil {
+				t.Fatal(err)
+			}
+			got, err := f.read(&buf)
+			if err != nil {
+				t.Fatalf("%v\n%s", err, buf.String())
+			}
+			if got.Initial.SFEN(1) != r.Initial.SFEN(1) {
+				t.Errorf("開始局面 = %s, want %s", got.Initial.SFEN(1), r.Initial.SFEN(1))
+			}
+			if len(got.Moves) != len(r.Moves) {
+				t.Fatalf("%d手, want %d手", len(got.Moves), len(r.Moves))
+			}
+			for i := range r.Moves {
+				if got.Moves[i] != r.Moves[i] {
+					t.Errorf("%d手目 = %v, want %v", i+1, got.Moves[i], r.Moves[i])
+				}
+			}
+			if got.End != r.End {
+				t.Errorf("End = %s, want %s", got.End, r.End)
+			}
+		})
+	}
+}
+
+func TestParseCSAMove(t *testing.T) {
+	tests := []struct {
+		sfen  string
+		input string
+		want  Move
+		ok    bool
+	}{
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "+2514KA", Move{4, 3, 3, 4, false, Empty, false}, true},
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "+2514HI", Move{}, false}, // 25の駒は角
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "+2514UM", Move{}, false}, // 敵陣に入らないので成れない
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "-5152FU", Move{}, false}, // 手番が違う
+		{"2k1+B/2B2/1G3/5/1K3 b - 1", "+1122UM", Move{0, 4, 1, 3, false, Empty, false}, true},
+		{"2k1+B/2B2/1G3/5/1K3 b - 1", "+1122KA", Move{}, false}, // 11の駒は馬
+		{"4k/P4/5/5/K4 b - 1", "+5251TO", Move{1, 0, 0, 0, false, Empty, true}, true},
+		{"4k/P4/5/5/K4 b - 1", "+5251KI", Move{}, false},
+		{"5/k4/2+R2/1s3/3K1 b G2P 1", "+0042KI", Move{-1, -1, 1, 1, true, Gold, false}, true},
+		{"5/k4/2+R2/1s3/3K1 b G2P 1", "+0042TO", Move{}, false}, // 成り駒は打てない
+		{"5/k4/2+R2/1s3/3K1 b G2P 1", "+0042GI", Move{}, false}, // 持っていない
+	}
+	for _, tt := range tests {
+		got, err := parseCSAMove(mustSFEN(t, tt.sfen), tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseCSAMove(%q) のエラー = %v, want ok=%v", tt.input, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("parseCSAMove(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestValidateMove(t *testing.T) {
+	nodrops := NewBoard()
+	nodrops.Rules = Minishogi{NoDrops: true}
+	tests := []struct {
+		name  string
+		board *Board
+		move  Move
+		want  error
+	}{
+		{"盤の外", NewBoard(), Move{3, 0, 5, 0, false, Empty, false}, ErrOutOfBoard},
+		{"駒がない", NewBoard(), Move{2, 2, 1, 2, false, Empty, false}, ErrNoPiece},
+		{"相手の駒", NewBoard(), Move{0, 0, 1, 0, false, Empty, false}, ErrNotYourPiece},
+		{"持っていない駒", NewBoard(), Move{-1, -1, 2, 2, true, Pawn, false}, ErrNotInHand},
+		{"動けない先", NewBoard(), Move{4, 0, 2, 0, false, Empty, false}, ErrCannotMove},
+		{"二歩", mustSFEN(t, "rbsgk/4p/5/P4/KGSBR b P 1"), Move{-1, -1, 2, 0, true, Pawn, false}, ErrNifu},
+		{"駒のあるマスに打つ", mustSFEN(t, "rbsgk/4p/5/P4/KGSBR b G 1"), Move{-1, -1, 0, 0, true, Gold, false}, ErrDropOnOccupied},
+		{"玉が取られる", mustSFEN(t, "r3k/5/5/5/1K3 b - 1"), Move{4, 1, 4, 0, false, Empty, false}, ErrLeavesKingInCheck},
+		{"行き所のない駒", mustSFEN(t, "4k/P4/5/5/K4 b - 1"), Move{1, 0, 0, 0, false, Empty, false}, ErrDeadPiece},
+		{"打ち歩詰め", mustSFEN(t, "3rk/5/3G1/5/K4 b P 1"), Move{-1, -1, 1, 4, true, Pawn, false}, ErrUchifuzume},
+		{"持ち駒なしのルール", nodrops, Move{-1, -1, 2, 2, true, Pawn, false}, ErrNoDrops},
+		{"合法手", NewBoard(), Move{3, 0, 2, 0, false, Empty, false}, nil},
+	}
+	for _, tt := range tests {
+		err := tt.board.ValidateMove(tt.move)
+		if !errors.Is(err, tt.want) || (tt.want == nil) != (err == nil) {
+			t.Errorf("%s: ValidateMove(%v) = %v, want %v", tt.name, tt.move, err, tt.want)
+			continue
+		}
+		var illegal *IllegalMoveError
+		if err != nil && !errors.As(err, &illegal) {
+			t.Errorf("%s: %T は IllegalMoveError ではありません", tt.name, err)
+		}
+	}
+}
+
+func TestMateIn(t *testing.T) {
+	for _, p := range builtinProblems() {
+		if p.Mate == 0 {
+			continue
+		}
+		m := p.Board.MateIn(p.Mate)
+		if m == nil {
+			t.Errorf("%s: %d手詰が見つかりません", p.Title, p.Mate)
+			continue
+		}
+		if !p.Board.MatesWith(*m, p.Mate) {
+			t.Errorf("%s: %v では詰みません", p.Title, *m)
+		}
+		if p.Mate > 1 && p.Board.MateIn(p.Mate-2) != nil {
+			t.Errorf("%s: %d手より短い詰みがあります", p.Title, p.Mate)
+		}
+	}
+	if m := NewBoard().MateIn(1); m != nil {
+		t.Errorf("初期局面の1手詰 = %v, want nil", *m)
+	}
+}
+
+func TestParseCorrespondenceMove(t *testing.T) {
+	forced := "4k/P4/5/5/K4 b - 1" // 1二の歩は成らないと指せない
+	tests := []struct {
+		sfen  string
+		input string
+		want  Move
+		ok    bool
+	}{
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "1413", Move{3, 0, 2, 0, false, Empty, false}, true},
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "+5453FU", Move{3, 0, 2, 0, false, Empty, false}, true},
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "+5553FU", Move{}, false},
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "1412", Move{}, false},
+		{"rbsgk/4p/5/P4/KGSBR b - 1", "abc", Move{}, false},
+		{forced, "1211", Move{1, 0, 0, 0, false, Empty, true}, true},
+		{forced, "1211+", Move{1, 0, 0, 0, false, Empty, true}, true},
+		{forced, "1211=", Move{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseCorrespondenceMove(mustSFEN(t, tt.sfen), tt.input)
+		if (err == nil) != tt.ok {
+			t.Errorf("parseCorrespondenceMove(%q) のエラー = %v, want ok=%v", tt.input, err, tt.ok)
+			continue
+		}
+		if tt.ok && got != tt.want {
+			t.Errorf("parseCorrespondenceMove(%q) = %v, want %v", tt.input, got, tt.want)
+		}
+	}
+}
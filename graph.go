@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 評価値グラフの目盛り（1段あたりの評価値と、上下の段数）
+const (
+	graphStep = 500
+	graphRows = 4
+)
+
+// 棋譜の各手の評価値（評価値のない手は直前の値を使う）
+func (r *Record) scores() []int {
+	scores := make([]int, len(r.Moves))
+	prev := 0
+	for i := range r.Moves {
+		if i < len(r.Notes) && r.Notes[i].Score != nil {
+			prev = *r.Notes[i].Score
+		}
+		scores[i] = prev
+	}
+	return scores
+}
+
+// 評価値の推移のグラフ（横が手数、縦が先手から見た評価値。上下の端を超える値は端に描く）
+func evalGraph(scores []int) []string {
+	var lines []string
+	lines = append(lines, tr("評価値の推移（先手から見た値）"))
+	for row := graphRows; row >= -graphRows; row-- {
+		label := ""
+		if row%2 == 0 {
+			label = fmt.Sprintf("%+d", row*graphStep)
+			if row == 0 {
+				label = "0"
+			}
+		}
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%6s |", label)
+		for _, s := range scores {
+			switch {
+			case graphRow(s) == row:
+				sb.WriteByte('*')
+			case row == 0:
+				sb.WriteByte('-')
+			default:
+				sb.WriteByte(' ')
+			}
+		}
+		lines = append(lines, strings.TrimRight(sb.String(), " "))
+	}
+
+	// 1手目と10手ごとの目盛り
+	axis := []byte(strings.Repeat(" ", len(scores)+3))
+	axis[0] = '1'
+	for n := 10; n <= len(scores); n += 10 {
+		copy(axis[n-1:], fmt.Sprint(n))
+	}
+	lines = append(lines, "        "+strings.TrimRight(string(axis), " "))
+	return lines
+}
+
+// 評価値を描く段（0が互角、正が先手有利）
+func graphRow(score int) int {
+	row := (score + graphStep/2*sign(score)) / graphStep
+	return max(-graphRows, min(graphRows, row))
+}
+
+func sign(n int) int {
+	switch {
+	case n > 0:
+		return 1
+	case n < 0:
+		return -1
+	}
+	return 0
+}
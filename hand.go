@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// プレイヤーの持ち駒
+func (b *Board) handOf(player Player) []PieceType {
+	if player == Second {
+		return b.SecondHand
+	}
+	return b.FirstHand
+}
+
+// 手番の側の打てる持ち駒と入力する文字（例: p=歩×2, s=銀×1）
+func (b *Board) droppableText() string {
+	counts := make(map[PieceType]int)
+	for _, p := range b.handOf(b.CurrentTurn) {
+		counts[p]++
+	}
+	var items []string
+	for _, d := range dropLetters {
+		if n := counts[d.pType]; n > 0 {
+			items = append(items, fmt.Sprintf("%c=%s×%d", d.letter, currentTheme.pieceName(d.pType), n))
+		}
+	}
+	if len(items) == 0 {
+		return tr("なし")
+	}
+	return strings.Join(items, ", ")
+}
+
+// hand コマンド: 打てる持ち駒と、打つときに入力する文字を表示
+func (g *Game) printHand(w io.Writer) {
+	if !g.Board.rules().Drops() {
+		fmt.Fprintln(w, tr(ErrNoDrops.Error()))
+		return
+	}
+	fmt.Fprintf(w, tr("打てる持ち駒: %s（p53 のように、駒の文字と打つマスを入力）")+"\n", g.Board.droppableText())
+}
+
+// 打つ駒の指定の誤りを詳しく説明する（入力が打つ手の形でなければfalse）
+func (g *Game) explainDropInput(input string) bool {
+	input = strings.ToLower(strings.TrimSpace(input))
+	if len(input) != 3 || isDigit(input[0]) || !isDigit(input[1]) || !isDigit(input[2]) {
+		return false
+	}
+	if _, ok := dropPieceType(input[0]); ok {
+		return false // 駒の文字は正しく、マスが盤の外
+	}
+	var letters []string
+	for _, d := range dropLetters {
+		letters = append(letters, fmt.Sprintf("%c=%s", d.letter, currentTheme.pieceName(d.pType)))
+	}
+	g.printError("invalid_drop_piece", fmt.Sprintf(tr("打つ駒の文字が違います: %c（%s）"), input[0], strings.Join(letters, ", ")))
+	return true
+}
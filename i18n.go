@@ -0,0 +1,111 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 表示メッセージの訳（messages/<言語>.json。キーは日本語の原文）
+// 訳のないメッセージは日本語のまま表示するので、訳は少しずつ追加できる。
+//
+//go:embed messages/*.json
+var messageFiles embed.FS
+
+// 現在の言語の訳（nilなら日本語）
+var messages map[string]string
+
+// メッセージを現在の言語に訳す
+func tr(s string) string {
+	if t, ok := messages[s]; ok {
+		return t
+	}
+	return s
+}
+
+// フラグの一覧を現在の言語で表示する（説明は日本語の原文で登録しておき、表示するときに訳す）
+// choicesは、説明の %s に埋め込む選べる値の一覧（フラグ名から）。
+func printDefaults(fs *flag.FlagSet, choices map[string]string) {
+	usages := make(map[string]string)
+	fs.VisitAll(func(f *flag.Flag) {
+		usages[f.Name] = f.Usage
+		f.Usage = tr(f.Usage)
+		if c, ok := choices[f.Name]; ok {
+			f.Usage = fmt.Sprintf(f.Usage, c)
+		}
+	})
+	fs.PrintDefaults()
+	fs.VisitAll(func(f *flag.Flag) {
+		f.Usage = usages[f.Name]
+	})
+}
+
+// サブコマンドの使い方（1行目の「使い方: ...」とフラグの一覧）を表示するようにする
+func setUsage(fs *flag.FlagSet, usage string) {
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), tr(usage))
+		printDefaults(fs, nil)
+	}
+}
+
+// 組み込みの言語（日本語を含む）
+func languageNames() []string {
+	names := []string{"ja"}
+	entries, _ := messageFiles.ReadDir("messages")
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// 表示する言語を設定（言語名か、訳を書いたJSONファイルのパス）
+func setLanguage(lang string) error {
+	if lang == "" || lang == "ja" {
+		messages = nil
+		return nil
+	}
+	var data []byte
+	var err error
+	if strings.HasSuffix(lang, ".json") {
+		data, err = os.ReadFile(lang)
+	} else {
+		data, err = messageFiles.ReadFile(path.Join("messages", lang+".json"))
+		if err != nil {
+			return fmt.Errorf(tr("未対応の言語です: %s（%s）"), lang, strings.Join(languageNames(), ", "))
+		}
+	}
+	if err != nil {
+		return err
+	}
+	m := make(map[string]string)
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("%s: %v", lang, err)
+	}
+	messages = m
+	return nil
+}
+
+// 環境変数（LC_ALL, LC_MESSAGES, LANG）から言語を決める（訳がない言語なら日本語）
+func languageFromEnv() string {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(key)
+		if v == "" {
+			continue
+		}
+		lang, _, _ := strings.Cut(v, ".")
+		lang, _, _ = strings.Cut(lang, "_")
+		for _, name := range languageNames() {
+			if name == lang {
+				return lang
+			}
+		}
+		return "ja"
+	}
+	return "ja"
+}
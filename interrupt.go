@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// 中断を表すキャンセルの理由
+var errInterrupted = errors.New("中断")
+
+// 対局を中断する（Ctrl-Cなど。別のゴルーチンから呼べる）
+// 探索や入力待ちをその場で打ち切り、棋譜を保存して Run を終える。
+func (g *Game) Interrupt() {
+	g.stop(errInterrupted)
+}
+
+// 対局が中断されたか
+func (g *Game) Interrupted() bool {
+	return context.Cause(g.base) == errInterrupted
+}
+
+// 終了を表すキャンセルの理由（quit）
+var errQuit = errors.New("終了")
+
+// 入力が終わったことを表すキャンセルの理由（quit と同じように対局をやめる）
+var errEndOfInput = errors.New("入力の終わり")
+
+// 対局をやめる（-save があれば棋譜を保存して Run を終える）
+func (g *Game) Quit() {
+	g.stop(errQuit)
+}
+
+// 中断したか、対局をやめたか
+func (g *Game) stopped() bool {
+	return g.base.Err() != nil
+}
+
+// 中断した対局の棋譜を保存（保存先の指定がなければ日時から名前を付ける）
+func (g *Game) suspend() {
+	fmt.Fprintln(g.out, "\n"+tr("対局を中断しました"))
+	g.sendRemote("%CHUDAN")
+	if g.SaveFile == "" {
+		g.SaveFile = g.now.Now().Format("mini-syogi-20060102-150405.csa")
+	}
+	if g.save() {
+		fmt.Fprintf(g.out, tr("-load %s で続きから指せます")+"\n", g.SaveFile)
+	}
+	g.emitJSON(jsonEvent{Type: "suspend", SFEN: g.Board.SFEN(len(g.Record.Moves) + 1)})
+}
+
+// Ctrl-C（SIGINT）とSIGTERMで対局を中断する（もう一度押すとすぐに終了する）
+func handleInterrupt(g *Game) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		g.Interrupt()
+		<-sig
+		os.Exit(130)
+	}()
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JSON出力の1行（種類ごとに使う項目だけを出力する）
+type jsonEvent struct {
+	// start, prompt, ai, book, resign, move, check, error, result, suspend, quit,
+	// hand, bod, threats, show, note（コマンドへの返答）, chat, coach, training
+	Type    string   `json:"type"`
+	Player  string   `json:"player,omitempty"`  // sente または gote
+	Move    string   `json:"move,omitempty"`    // 指し手（CSA形式。trainingでは最善手）
+	SFEN    string   `json:"sfen,omitempty"`    // 局面
+	Score   *int     `json:"score,omitempty"`   // AIの評価値（先手から見た値。trainingでは最善手の評価値）
+	Played  *int     `json:"played,omitempty"`  // trainingで、指した手の評価値（先手から見た値）
+	Depth   int      `json:"depth,omitempty"`   // AIの探索深さ
+	Elapsed int64    `json:"elapsed,omitempty"` // 消費時間（ミリ秒）
+	Prompt  string   `json:"prompt,omitempty"`  // 入力待ちの種類（mode, move, promote, quit, rematch）
+	Message string   `json:"message,omitempty"` // エラーの内容、チャットのメッセージ、コメント
+	Code    string   `json:"code,omitempty"`    // エラーの種類（下の jsonErrorCodes など。表示の言語によらない）
+	Text    string   `json:"text,omitempty"`    // 画面に表示する文（盤面図などを含む）
+	Square  string   `json:"square,omitempty"`  // showで指定したマス（例: 53）
+	Pieces  []string `json:"pieces,omitempty"`  // handで、打てる持ち駒（CSA形式の駒の種類。枚数だけ並べる）
+	Ply     int      `json:"ply,omitempty"`     // noteで、コメントを付けた手数
+	First   string   `json:"first,omitempty"`   // 先手の対局者名
+	Second  string   `json:"second,omitempty"`  // 後手の対局者名
+	Result  string   `json:"result,omitempty"`  // sente_win, gote_win, draw
+	// resultでは mate, resign, timeout, repetition, illegal_move, try
+	// quitでは quit, eof, end_of_script, bad_script
+	Reason string `json:"reason,omitempty"`
+}
+
+var jsonPlayers = map[Player]string{First: "sente", Second: "gote"}
+
+var jsonOutcomes = map[Outcome]string{SenteWin: "sente_win", GoteWin: "gote_win", Draw: "draw"}
+
+var jsonReasons = map[Reason]string{
+	ReasonMate:        "mate",
+	ReasonResign:      "resign",
+	ReasonTimeout:     "timeout",
+	ReasonRepetition:  "repetition",
+	ReasonIllegalMove: "illegal_move",
+	ReasonTry:         "try",
+}
+
+// 指せない手の理由ごとのエラーの種類
+var jsonErrorCodes = map[error]string{
+	ErrOutOfBoard:        "out_of_board",
+	ErrNoPiece:           "no_piece",
+	ErrNotYourPiece:      "not_your_piece",
+	ErrNotInHand:         "not_in_hand",
+	ErrCannotMove:        "cannot_move",
+	ErrNifu:              "nifu",
+	ErrDropOnOccupied:    "drop_on_occupied",
+	ErrLeavesKingInCheck: "leaves_king_in_check",
+	ErrDeadPiece:         "dead_piece",
+	ErrUchifuzume:        "uchifuzume",
+	ErrNoDrops:           "no_drops",
+}
+
+// エラーの種類（指せない手なら理由から決め、それ以外はfallback）
+func errorCode(err error, fallback string) string {
+	var ime *IllegalMoveError
+	if errors.As(err, &ime) {
+		err = ime.Err
+	}
+	if code, ok := jsonErrorCodes[err]; ok {
+		return code
+	}
+	return fallback
+}
+
+// 出力を1行ごとのJSONにする（画面向けの表示はすべて捨てる）
+func (g *Game) UseJSON(w io.Writer) {
+	g.jsonOut = json.NewEncoder(w)
+	g.out = io.Discard
+	g.OnCheck(func(e CheckEvent) {
+		g.emitJSON(jsonEvent{Type: "check", Player: jsonPlayers[e.Player]})
+	})
+}
+
+// JSONを1行出力（JSON出力でなければ何もしない）
+func (g *Game) emitJSON(e jsonEvent) {
+	if g.jsonOut != nil {
+		g.jsonOut.Encode(e)
+	}
+}
+
+// 対局開始
+func (g *Game) jsonStart() {
+	g.emitJSON(jsonEvent{
+		Type:   "start",
+		SFEN:   g.Board.SFEN(len(g.Record.Moves) + 1),
+		First:  g.Record.FirstName,
+		Second: g.Record.SecondName,
+	})
+}
+
+// 入力待ち
+func (g *Game) jsonPrompt(prompt string) {
+	e := jsonEvent{Type: "prompt", Prompt: prompt}
+	if prompt == "move" || prompt == "promote" {
+		e.Player = jsonPlayers[g.Board.CurrentTurn]
+	}
+	g.emitJSON(e)
+}
+
+// AIが選んだ手（beforeは指す前の盤面）
+func (g *Game) jsonAIMove(before *Board, r SearchResult) {
+	g.emitJSON(jsonEvent{
+		Type:   "ai",
+		Player: jsonPlayers[before.CurrentTurn],
+		Move:   csaMove(before, *r.Move),
+		Score:  &r.Score,
+		Depth:  r.Depth,
+	})
+}
+
+// 指した手と指した後の局面（棋譜に加えた後に呼ぶ。beforeは指す前の盤面）
+func (g *Game) jsonMove(before *Board, move Move, elapsed time.Duration) {
+	g.emitJSON(jsonEvent{
+		Type:    "move",
+		Player:  jsonPlayers[before.CurrentTurn],
+		Move:    csaMove(before, move),
+		SFEN:    g.Board.SFEN(len(g.Record.Moves) + 1),
+		Elapsed: elapsed.Milliseconds(),
+	})
+}
+
+// 対局結果
+func (g *Game) jsonResult() {
+	g.emitJSON(jsonEvent{
+		Type:   "result",
+		Result: jsonOutcomes[g.Result.Outcome],
+		Reason: jsonReasons[g.Result.Reason],
+		SFEN:   g.Board.SFEN(len(g.Record.Moves) + 1),
+	})
+}
+
+// エラーを表示（JSON出力ではerrorの行にする。codeはエラーの種類）
+func (g *Game) printError(code string, a ...any) {
+	text := fmt.Sprintln(a...)
+	fmt.Fprint(g.out, text)
+	g.emitJSON(jsonEvent{Type: "error", Code: code, Message: text[:len(text)-1]})
+}
+
+// コマンドへの返答などを表示（JSON出力では、表示する文をtextに入れたeの行にする）
+func (g *Game) printReply(e jsonEvent, print func(w io.Writer)) {
+	if g.jsonOut == nil {
+		print(g.out)
+		return
+	}
+	var text strings.Builder
+	print(&text)
+	// 返答の代わりにエラーを表示した場合は、errorの行だけにする
+	if e.Text = strings.Trim(text.String(), "\n"); e.Text != "" {
+		g.emitJSON(e)
+	}
+}
+
+// 対局をやめた（quit、入力の終わり、台本の終わり）
+func (g *Game) jsonQuit(reason string) {
+	g.emitJSON(jsonEvent{Type: "quit", Reason: reason, SFEN: g.Board.SFEN(len(g.Record.Moves) + 1)})
+}
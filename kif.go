@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KIF形式の筋（盤の右から数える。CSA形式と同じ）
+var kifFiles = []string{"１", "２", "３", "４", "５", "６", "７", "８", "９"}
+
+// KIF形式の駒の名前（成り駒は2文字で書く）
+var kifPieces = map[PieceType]string{
+	King:           "玉",
+	Gold:           "金",
+	Silver:         "銀",
+	Bishop:         "角",
+	Rook:           "飛",
+	Pawn:           "歩",
+	PromotedSilver: "成銀",
+	PromotedBishop: "馬",
+	PromotedRook:   "龍",
+	PromotedPawn:   "と",
+}
+
+// 終局の特殊手のKIF形式の表記
+var kifEnds = map[string]string{
+	"%TORYO":        "投了",
+	"%TSUMI":        "詰み",
+	"%TIME_UP":      "切れ負け",
+	"%SENNICHITE":   "千日手",
+	"%ILLEGAL_MOVE": "反則負け",
+	"%CHUDAN":       "中断",
+	"%TRY":          "トライ",
+}
+
+// KIF形式の指し手（例: １四飛(15)、同　角成(41)、３三歩打）
+// prevは直前の手（移動先が同じなら「同　」と書く。初手ならnil）
+func kifMove(b *Board, m Move, prev *Move) string {
+	dest := kifFiles[b.Size()-1-m.ToCol] + rankNames[m.ToRow]
+	if prev != nil && prev.ToRow == m.ToRow && prev.ToCol == m.ToCol {
+		dest = "同　"
+	}
+	if m.IsDrop {
+		return dest + kifPieces[m.DropPiece] + "打"
+	}
+	text := dest + kifPieces[b.Cells[m.FromRow][m.FromCol].Type]
+	if m.Promote {
+		text += "成"
+	}
+	return text + fmt.Sprintf("(%d%d)", b.Size()-m.FromCol, m.FromRow+1)
+}
+
+// KIF形式の消費時間（この手の時間/その側の累計）
+func kifTime(move, total time.Duration) string {
+	m := int(move.Seconds())
+	t := int(total.Seconds())
+	return fmt.Sprintf("(%2d:%02d/%02d:%02d:%02d)", m/60, m%60, t/3600, t/60%60, t%60)
+}
+
+// 棋譜をKIF形式で書き出し（各手の消費時間と、その側の累計も書く）
+func (r *Record) WriteKIF(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# ---- %s 棋譜ファイル ----\n", programID())
+	if name := r.Initial.rules().Name(); name != "" {
+		fmt.Fprintf(bw, "# 変則ルール: %s\n", name)
+	}
+	if r.Initial.SFEN(1) == NewBoard().SFEN(1) {
+		fmt.Fprintln(bw, "手合割：５五将棋")
+	} else {
+		r.Initial.WriteBOD(bw)
+	}
+	if r.Depths[First] > 0 {
+		fmt.Fprintf(bw, "# 先手の探索深度: %d\n", r.Depths[First])
+	}
+	if r.Depths[Second] > 0 {
+		fmt.Fprintf(bw, "# 後手の探索深度: %d\n", r.Depths[Second])
+	}
+	fmt.Fprintf(bw, "先手：%s\n", r.FirstName)
+	fmt.Fprintf(bw, "後手：%s\n", r.SecondName)
+	fmt.Fprintln(bw, "手数----指手---------消費時間--")
+
+	r.writeKIFMoves(bw, r.Moves, 0, r.Times, r.Notes, r.Variations)
+	if end, ok := kifEnds[r.End]; ok {
+		fmt.Fprintf(bw, "%4d %s\n", len(r.Moves)+1, end)
+	}
+	for _, line := range r.Trailer {
+		fmt.Fprintf(bw, "#%s\n", line)
+	}
+
+	// 変化（元の手順の後に、後の手数で分かれるものから書く。変化の手には消費時間も注釈もない）
+	r.walkVariations(func(v *Variation, moves []Move) {
+		fmt.Fprintf(bw, "\n変化：%d手\n", v.Ply+1)
+		r.writeKIFMoves(bw, moves, v.Ply, nil, nil, v.Variations)
+	})
+	return bw.Flush()
+}
+
+// 指し手の列のfrom手目より後をKIF形式で書き出す（branchesの変化が分かれる手に「+」を付ける）
+func (r *Record) writeKIFMoves(w io.Writer, moves []Move, from int, times []time.Duration, notes []Annotation, branches []Variation) {
+	b := r.Initial.Clone()
+	var total [3]time.Duration
+	var prev *Move
+	for i, m := range moves {
+		var elapsed time.Duration
+		if i < len(times) {
+			elapsed = times[i]
+		}
+		total[b.CurrentTurn] += elapsed
+		if i >= from {
+			branch := ""
+			if slices.ContainsFunc(branches, func(v Variation) bool { return v.Ply == i }) {
+				branch = "+"
+			}
+			fmt.Fprintf(w, "%4d %s   %s%s\n", i+1, padKIF(kifMove(b, m, prev), 12), kifTime(elapsed, total[b.CurrentTurn]), branch)
+			if i < len(notes) {
+				writeKIFNote(w, notes[i])
+			}
+		}
+		b.ApplyLegal(m)
+		prev = &moves[i]
+	}
+}
+
+// 注釈をKIF形式のコメント行で書き出し（評価値は「**評価値 N」の行にする）
+func writeKIFNote(w io.Writer, note Annotation) {
+	if note.Score != nil {
+		fmt.Fprintf(w, "**評価値 %d\n", *note.Score)
+	}
+	if note.Comment != "" {
+		for _, line := range strings.Split(note.Comment, "\n") {
+			fmt.Fprintf(w, "*%s\n", line)
+		}
+	}
+}
+
+// 指し手の欄を表示幅で揃える（全角は2桁として数える）
+func padKIF(s string, width int) string {
+	n := 0
+	for _, c := range s {
+		if c < 0x80 {
+			n++
+		} else {
+			n += 2
+		}
+	}
+	for ; n < width; n++ {
+		s += " "
+	}
+	return s
+}
+
+// KIF形式の指し手の行（手数、指し手、この手の消費時間、変化のある印）
+var kifMoveLine = regexp.MustCompile(`^\s*(\d+)\s+(.+?)\s*(?:\(\s*(\d+):(\d+)/[\d:]*\))?\s*(\+)?$`)
+
+// KIF形式の駒の別名（他のソフトが書く表記）
+var kifPieceAliases = map[string]PieceType{
+	"王": King,
+	"竜": PromotedRook,
+	"全": PromotedSilver,
+}
+
+// KIF形式の棋譜を読み込み（指し手は合法手かどうか検証する。コメントと評価値は注釈として残す）
+func ReadKIF(rd io.Reader) (*Record, error) {
+	r := &Record{}
+	var rules Rules
+	var bod []string // 開始局面の盤面図の行
+	var board *Board // 指し手を読み始めた後の現在局面
+	var prev *Move
+	branch := -1 // 読んでいる変化の分かれる手数（-1なら本譜）
+	var branchMoves []Move
+	// 読んだ手順（開始局面からの指し手と、その手順の分かれた手数）。
+	// 変化は、その手数の手を持つ最後に読んだ手順から分かれる。
+	type kifLine struct {
+		from  int
+		moves []Move
+	}
+	var lines []kifLine
+	endBranch := func() {
+		if branch < 0 {
+			lines = append(lines, kifLine{0, r.Moves})
+		} else if len(branchMoves) > 0 {
+			moves := append(append([]Move{}, lines[len(lines)-1].moves[:branch]...), branchMoves...)
+			r.AddLine(moves)
+			lines = append(lines, kifLine{branch, moves})
+		}
+	}
+
+	scanner := bufio.NewScanner(rd)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimRight(scanner.Text(), " \r")
+		match := kifMoveLine.FindStringSubmatch(line)
+		if board == nil {
+			// 開始局面（盤面図がなければ平手）
+			if match == nil && !strings.HasPrefix(line, "手数----") {
+				var err error
+				switch {
+				case strings.HasPrefix(line, "# 変則ルール: "):
+					rules, err = ParseRules(strings.TrimPrefix(line, "# 変則ルール: "))
+				case strings.HasPrefix(line, "# 先手の探索深度: "):
+					err = r.readDepth("+", strings.TrimPrefix(line, "# 先手の探索深度: "))
+				case strings.HasPrefix(line, "# 後手の探索深度: "):
+					err = r.readDepth("-", strings.TrimPrefix(line, "# 後手の探索深度: "))
+				case strings.HasPrefix(line, "手合割："):
+					if h := strings.TrimPrefix(line, "手合割："); h != "５五将棋" && h != "五々将棋" {
+						err = fmt.Errorf(tr("未対応の手合割です: %s"), h)
+					}
+				case strings.HasPrefix(line, "先手："):
+					r.FirstName = strings.TrimPrefix(line, "先手：")
+				case strings.HasPrefix(line, "後手："):
+					r.SecondName = strings.TrimPrefix(line, "後手：")
+				case !strings.HasPrefix(line, "#"):
+					bod = append(bod, line)
+				}
+				if err != nil {
+					return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+				}
+				continue
+			}
+			initial, err := kifInitial(rules, bod)
+			if err != nil {
+				return nil, err
+			}
+			r.Initial = initial
+			board = initial.Clone()
+			if match == nil {
+				continue
+			}
+		}
+
+		switch {
+		case match != nil:
+			ply, _ := strconv.Atoi(match[1])
+			want := len(r.Moves) + 1
+			if branch >= 0 {
+				want = branch + len(branchMoves) + 1
+			}
+			if ply != want {
+				return nil, fmt.Errorf(tr("%d行目: 手数が%dではありません"), lineNo, want)
+			}
+			if end := kifEndCode(match[2]); end != "" {
+				if branch < 0 {
+					r.End = end
+				}
+				continue
+			}
+			m, err := parseKIFMove(board, match[2], prev)
+			if err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+			if branch >= 0 {
+				branchMoves = append(branchMoves, m)
+			} else {
+				mins, _ := strconv.Atoi(match[3])
+				secs, _ := strconv.Atoi(match[4])
+				r.Add(m, time.Duration(mins*60+secs)*time.Second)
+			}
+			board.ApplyLegal(m)
+			prev = &m
+
+		case strings.HasPrefix(line, "変化："):
+			endBranch()
+			n, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "変化："), "手"))
+			for err == nil && len(lines) > 1 && (lines[len(lines)-1].from >= n || len(lines[len(lines)-1].moves) < n) {
+				lines = lines[:len(lines)-1]
+			}
+			if err != nil || n < 1 || n > len(lines[len(lines)-1].moves) {
+				return nil, fmt.Errorf(tr("%d行目: 変化の手数が不正です: %s"), lineNo, line)
+			}
+			parent := lines[len(lines)-1].moves
+			branch, branchMoves = n-1, nil
+			board = r.positionAfter(parent, branch)
+			prev = nil
+			if branch > 0 {
+				prev = &parent[branch-1]
+			}
+
+		case strings.HasPrefix(line, "*") && branch < 0:
+			if err := r.readKIFComment(line); err != nil {
+				return nil, fmt.Errorf(tr("%d行目: %v"), lineNo, err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if board == nil {
+		initial, err := kifInitial(rules, bod)
+		if err != nil {
+			return nil, err
+		}
+		r.Initial = initial
+	}
+	endBranch()
+	return r, nil
+}
+
+// KIF形式の開始局面（盤面図があればそれを、なければ変則ルールの初期配置を使う）
+func kifInitial(rules Rules, bod []string) (*Board, error) {
+	if !slices.ContainsFunc(bod, func(line string) bool { return strings.HasPrefix(line, "|") }) {
+		return (&Board{Rules: rules}).rules().Setup(), nil
+	}
+	b, err := readBOD(strings.NewReader(strings.Join(bod, "\n")), rules)
+	if err != nil {
+		return nil, err
+	}
+	b.Rules = rules
+	return b, nil
+}
+
+// 終局の特殊手のKIF形式の表記をCSA形式に戻す（特殊手でなければ空文字列）
+func kifEndCode(text string) string {
+	for code, name := range kifEnds {
+		if name == text {
+			return code
+		}
+	}
+	return ""
+}
+
+// コメント行を読み込み（「**評価値 N」は評価値、それ以外の「**」は読み飛ばし、「*」はコメント）
+func (r *Record) readKIFComment(line string) error {
+	if len(r.Notes) == 0 {
+		return nil
+	}
+	switch {
+	case strings.HasPrefix(line, "**評価値 "):
+		score, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "**評価値 ")))
+		if err != nil {
+			return fmt.Errorf(tr("評価値が不正です: %s"), line)
+		}
+		r.Notes[len(r.Notes)-1].Score = &score
+	case strings.HasPrefix(line, "**"):
+	default:
+		r.AddComment(line[1:])
+	}
+	return nil
+}
+
+// KIF形式の指し手を解析して合法手と照合（prevは直前の手。「同」の移動先に使う）
+func parseKIFMove(b *Board, s string, prev *Move) (Move, error) {
+	var m Move
+	text := s
+	if rest, ok := strings.CutPrefix(text, "同"); ok {
+		if prev == nil {
+			return Move{}, fmt.Errorf(tr("直前の手がないのに「同」があります: %s"), s)
+		}
+		m.ToRow, m.ToCol = prev.ToRow, prev.ToCol
+		text = strings.TrimLeft(rest, "　 ")
+	} else {
+		runes := []rune(text)
+		if len(runes) < 2 {
+			return Move{}, fmt.Errorf(tr("指し手の形式が不正です: %s"), s)
+		}
+		file := slices.Index(kifFiles[:b.Size()], string(runes[0]))
+		rank := slices.Index(rankNames[:b.Size()], string(runes[1]))
+		if file < 0 || rank < 0 {
+			return Move{}, fmt.Errorf(tr("移動先が不正です: %s"), s)
+		}
+		m.ToRow, m.ToCol = rank, b.Size()-1-file
+		text = string(runes[2:])
+	}
+
+	pType, text, ok := cutKIFPiece(text)
+	if !ok {
+		return Move{}, fmt.Errorf(tr("駒の種類が不正です: %s"), s)
+	}
+	if text == "打" {
+		m.FromRow, m.FromCol, m.IsDrop, m.DropPiece = -1, -1, true, pType
+	} else {
+		if rest, ok := strings.CutPrefix(text, "不成"); ok {
+			text = rest
+		} else if rest, ok := strings.CutPrefix(text, "成"); ok {
+			m.Promote, text = true, rest
+		}
+		if len(text) != 4 || text[0] != '(' || text[3] != ')' || !isDigit(text[1]) || !isDigit(text[2]) {
+			return Move{}, fmt.Errorf(tr("移動元が不正です: %s"), s)
+		}
+		file, rank := int(text[1]-'0'), int(text[2]-'0')
+		if file < 1 || file > b.Size() || rank < 1 || rank > b.Size() {
+			return Move{}, fmt.Errorf(tr("移動元が不正です: %s"), s)
+		}
+		m.FromRow, m.FromCol = rank-1, b.Size()-file
+		if b.Cells[m.FromRow][m.FromCol].Type != pType {
+			return Move{}, fmt.Errorf(tr("移動元の駒が違います: %s"), s)
+		}
+	}
+
+	if err := b.ValidateMove(m); err != nil {
+		return Move{}, fmt.Errorf("%s: %v", s, err)
+	}
+	return m, nil
+}
+
+// 先頭の駒の名前を切り出す
+func cutKIFPiece(s string) (PieceType, string, bool) {
+	for pType, name := range kifPieces {
+		if rest, ok := strings.CutPrefix(s, name); ok {
+			return pType, rest, true
+		}
+	}
+	for name, pType := range kifPieceAliases {
+		if rest, ok := strings.CutPrefix(s, name); ok {
+			return pType, rest, true
+		}
+	}
+	return Empty, s, false
+}
+
+// KIF形式の棋譜ファイルか（拡張子で判断する）
+func isKIFFile(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".kif")
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
-	"bufio"
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -36,6 +37,17 @@ const (
 	Second
 )
 
+// 相手のプレイヤー
+func (p Player) Opponent() Player {
+	switch p {
+	case First:
+		return Second
+	case Second:
+		return First
+	}
+	return None
+}
+
 // 駒
 type Piece struct {
 	Type  PieceType
@@ -44,10 +56,11 @@ type Piece struct {
 
 // 盤面
 type Board struct {
-	Cells       [5][5]Piece
+	Cells       [][]Piece   // 盤上の駒（Cells[段][筋]。一辺のマス数はルールで決まる）
 	FirstHand   []PieceType // 先手の持ち駒
 	SecondHand  []PieceType // 後手の持ち駒
 	CurrentTurn Player
+	Rules       Rules // ルール（nilなら標準の5五将棋）
 }
 
 // 移動
@@ -61,30 +74,70 @@ type Move struct {
 
 // ゲーム初期化
 func NewBoard() *Board {
-	b := &Board{
+	return Minishogi{}.Setup()
+}
+
+// 盤の一辺のマス数の上限（入力やCSA形式で筋と段を1桁の数字で書くため）
+const maxBoardSize = 9
+
+// 空の盤面（一辺sizeマス、先手番）
+// sizeはルールの Size か、newBoardOfSize で確かめた値を渡す。範囲外の大きさはプログラムの誤りなのでpanicする。
+func newEmptyBoard(size int) *Board {
+	if size < 1 || size > maxBoardSize {
+		panic(fmt.Sprintf(tr("盤の大きさは1〜%dです: %d"), maxBoardSize, size))
+	}
+	return &Board{
+		Cells:       makeCells(size),
 		FirstHand:   []PieceType{},
 		SecondHand:  []PieceType{},
 		CurrentTurn: First,
 	}
+}
+
+// 読み込んだ盤面の段数rowsがルールの盤の大きさと合うか確かめて、その大きさの空の盤面を作る（rulesがnilなら標準のルール）
+func newBoardOfSize(rows int, rules Rules) (*Board, error) {
+	if rules == nil {
+		rules = Minishogi{}
+	}
+	if rows != rules.Size() {
+		return nil, fmt.Errorf(tr("盤面の段数がルールの盤の大きさ（%d段）と違います: %d段"), rules.Size(), rows)
+	}
+	return newEmptyBoard(rows), nil
+}
 
-	// 初期配置（5五将棋の標準配置）
-	// 後手（上側）
-	b.Cells[0][0] = Piece{Rook, Second}
-	b.Cells[0][1] = Piece{Bishop, Second}
-	b.Cells[0][2] = Piece{Silver, Second}
-	b.Cells[0][3] = Piece{Gold, Second}
-	b.Cells[0][4] = Piece{King, Second}
-	b.Cells[1][4] = Piece{Pawn, Second}
+// 一辺sizeマスの空きマス（段ごとのスライスは1つの配列を共有する）
+func makeCells(size int) [][]Piece {
+	squares := make([]Piece, size*size)
+	cells := make([][]Piece, size)
+	for r := range cells {
+		cells[r] = squares[r*size : (r+1)*size]
+	}
+	return cells
+}
 
-	// 先手（下側）
-	b.Cells[4][4] = Piece{Rook, First}
-	b.Cells[4][3] = Piece{Bishop, First}
-	b.Cells[4][2] = Piece{Silver, First}
-	b.Cells[4][1] = Piece{Gold, First}
-	b.Cells[4][0] = Piece{King, First}
-	b.Cells[3][0] = Piece{Pawn, First}
+// 盤の一辺のマス数
+func (b *Board) Size() int {
+	return len(b.Cells)
+}
 
-	return b
+// 対局のルール
+func (b *Board) rules() Rules {
+	if b.Rules == nil {
+		return Minishogi{}
+	}
+	return b.Rules
+}
+
+// 盤面のコピー（持ち駒も複製する）
+func (b *Board) Clone() *Board {
+	newBoard := *b
+	newBoard.Cells = makeCells(b.Size())
+	for r := range b.Cells {
+		copy(newBoard.Cells[r], b.Cells[r])
+	}
+	newBoard.FirstHand = append([]PieceType{}, b.FirstHand...)
+	newBoard.SecondHand = append([]PieceType{}, b.SecondHand...)
+	return &newBoard
 }
 
 // 駒の文字表現
@@ -93,29 +146,7 @@ func (p Piece) String() string {
 		return " ． "
 	}
 
-	var symbol string
-	switch p.Type {
-	case King:
-		symbol = "玉"
-	case Gold:
-		symbol = "金"
-	case Silver:
-		symbol = "銀"
-	case Bishop:
-		symbol = "角"
-	case Rook:
-		symbol = "飛"
-	case Pawn:
-		symbol = "歩"
-	case PromotedSilver:
-		symbol = "全"
-	case PromotedBishop:
-		symbol = "馬"
-	case PromotedRook:
-		symbol = "龍"
-	case PromotedPawn:
-		symbol = "と"
-	}
+	symbol := kanjiSymbols[p.Type]
 
 	if p.Owner == First {
 		return " " + symbol + " "
@@ -125,28 +156,56 @@ func (p Piece) String() string {
 }
 
 // 盤面表示
-func (b *Board) Display() {
-	fmt.Println("\n  １ ２ ３ ４ ５")
-	fmt.Println("┌─────────────┐")
-	for i := 0; i < 5; i++ {
-		fmt.Printf("│")
-		for j := 0; j < 5; j++ {
-			fmt.Printf("%s", b.Cells[i][j])
+func (b *Board) Display(w io.Writer) {
+	b.DisplayMove(w, nil)
+}
+
+// 直前の手の移動元と移動先に印を付けて盤面表示（lastがnilなら印を付けない）
+func (b *Board) DisplayMove(w io.Writer, last *Move) {
+	b.displayCells(w, func(r, c int) string {
+		s := currentTheme.cell(b.Cells[r][c])
+		if last != nil && (r == last.ToRow && c == last.ToCol || !last.IsDrop && r == last.FromRow && c == last.FromCol) {
+			return currentTheme.lastMoveCell(s)
+		}
+		return s
+	})
+}
+
+// マスごとの文字表現をcellで決めて盤面を表示
+func (b *Board) displayCells(w io.Writer, cell func(r, c int) string) {
+	t := currentTheme
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, t.header(b.Size()))
+	if t.Top != "" {
+		fmt.Fprintln(w, t.rule(t.Top, b.Size()))
+	}
+	for i := 0; i < b.Size(); i++ {
+		if t.LeftRank {
+			fmt.Fprint(w, rankNames[i])
+		}
+		fmt.Fprint(w, t.LeftSide)
+		for j := 0; j < b.Size(); j++ {
+			fmt.Fprint(w, cell(i, j))
 		}
-		fmt.Printf("│%s\n", []string{"一", "二", "三", "四", "五"}[i])
+		fmt.Fprintf(w, "%s%s\n", t.RightSide, rankNames[i])
+	}
+	if t.Bottom != "" {
+		fmt.Fprintln(w, t.rule(t.Bottom, b.Size()))
+	}
+	if t.Footer {
+		fmt.Fprintln(w, t.header(b.Size()))
 	}
-	fmt.Println("└─────────────┘")
 
 	// 持ち駒表示
-	fmt.Printf("先手持ち駒: ")
-	b.displayHand(b.FirstHand)
-	fmt.Printf("後手持ち駒: ")
-	b.displayHand(b.SecondHand)
+	fmt.Fprint(w, tr("先手持ち駒: "))
+	b.displayHand(w, b.FirstHand)
+	fmt.Fprint(w, tr("後手持ち駒: "))
+	b.displayHand(w, b.SecondHand)
 }
 
-func (b *Board) displayHand(hand []PieceType) {
+func (b *Board) displayHand(w io.Writer, hand []PieceType) {
 	if len(hand) == 0 {
-		fmt.Println("なし")
+		fmt.Fprintln(w, tr("なし"))
 		return
 	}
 	counts := make(map[PieceType]int)
@@ -154,202 +213,293 @@ func (b *Board) displayHand(hand []PieceType) {
 		counts[p]++
 	}
 	for pType, count := range counts {
-		piece := Piece{Type: pType, Owner: First}
-		fmt.Printf("%s×%d ", strings.TrimSpace(piece.String()), count)
+		fmt.Fprintf(w, "%s×%d ", currentTheme.pieceName(pType), count)
 	}
-	fmt.Println()
+	fmt.Fprintln(w)
 }
 
 // 移動可能な位置を取得
 func (b *Board) GetPossibleMoves(row, col int) []Move {
+	moves := []Move{}
+	b.forEachPieceMove(row, col, func(m Move) bool {
+		moves = append(moves, m)
+		return true
+	})
+	return moves
+}
+
+// 駒の移動を1手ずつyieldに渡す（yieldがfalseを返したら打ち切ってfalseを返す）
+func (b *Board) forEachPieceMove(row, col int, yield func(Move) bool) bool {
 	piece := b.Cells[row][col]
 	if piece.Owner == None || piece.Owner != b.CurrentTurn {
-		return []Move{}
+		return true
 	}
 
-	moves := []Move{}
-
-	switch piece.Type {
-	case King:
-		// 8方向に1マス
-		dirs := [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
-		for _, d := range dirs {
-			nr, nc := row+d[0], col+d[1]
-			if b.isValidMove(row, col, nr, nc) {
-				moves = append(moves, Move{row, col, nr, nc, false, Empty, false})
-			}
-		}
-
-	case Gold, PromotedSilver, PromotedPawn:
-		// 金の動き
-		dirs := b.getGoldMoves(piece.Owner)
-		for _, d := range dirs {
-			nr, nc := row+d[0], col+d[1]
-			if b.isValidMove(row, col, nr, nc) {
-				moves = append(moves, Move{row, col, nr, nc, false, Empty, false})
+	rules := b.rules()
+	// 成れるなら成る手を先に、行き所のない駒になるなら成らない手は渡さない
+	emit := func(nr, nc int) bool {
+		if rules.CanPromote(piece, nr) {
+			if !yield(Move{row, col, nr, nc, false, Empty, true}) {
+				return false
 			}
 		}
-
-	case Silver:
-		// 銀の動き
-		dirs := b.getSilverMoves(piece.Owner)
-		for _, d := range dirs {
-			nr, nc := row+d[0], col+d[1]
-			if b.isValidMove(row, col, nr, nc) {
-				move := Move{row, col, nr, nc, false, Empty, false}
-				// 成りの判定
-				if b.canPromote(piece.Owner, nr) {
-					moves = append(moves, Move{row, col, nr, nc, false, Empty, true})
-				}
-				moves = append(moves, move)
-			}
+		if rules.MustPromote(piece, nr) {
+			return true
 		}
+		return yield(Move{row, col, nr, nc, false, Empty, false})
+	}
 
-	case Bishop, PromotedBishop:
-		// 斜め方向
-		dirs := [][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
-		for _, d := range dirs {
-			for i := 1; i < 5; i++ {
-				nr, nc := row+d[0]*i, col+d[1]*i
-				if !b.isInBoard(nr, nc) {
-					break
-				}
-				if b.Cells[nr][nc].Owner == piece.Owner {
-					break
-				}
-				move := Move{row, col, nr, nc, false, Empty, false}
-				if piece.Type == Bishop && b.canPromote(piece.Owner, nr) {
-					moves = append(moves, Move{row, col, nr, nc, false, Empty, true})
-				}
-				moves = append(moves, move)
-				if b.Cells[nr][nc].Owner != None {
-					break
-				}
-			}
-		}
-		// 馬の場合は1マス直進も可能
-		if piece.Type == PromotedBishop {
-			dirs = [][2]int{{-1, 0}, {0, -1}, {0, 1}, {1, 0}}
-			for _, d := range dirs {
-				nr, nc := row+d[0], col+d[1]
-				if b.isValidMove(row, col, nr, nc) {
-					moves = append(moves, Move{row, col, nr, nc, false, Empty, false})
-				}
+	// 飛び駒の動き
+	for _, d := range rules.Slides(piece) {
+		for i := 1; ; i++ {
+			nr, nc := row+d[0]*i, col+d[1]*i
+			if !b.isInBoard(nr, nc) || b.Cells[nr][nc].Owner == piece.Owner {
+				break
 			}
-		}
-
-	case Rook, PromotedRook:
-		// 直線方向
-		dirs := [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
-		for _, d := range dirs {
-			for i := 1; i < 5; i++ {
-				nr, nc := row+d[0]*i, col+d[1]*i
-				if !b.isInBoard(nr, nc) {
-					break
-				}
-				if b.Cells[nr][nc].Owner == piece.Owner {
-					break
-				}
-				move := Move{row, col, nr, nc, false, Empty, false}
-				if piece.Type == Rook && b.canPromote(piece.Owner, nr) {
-					moves = append(moves, Move{row, col, nr, nc, false, Empty, true})
-				}
-				moves = append(moves, move)
-				if b.Cells[nr][nc].Owner != None {
-					break
-				}
+			if !emit(nr, nc) {
+				return false
 			}
-		}
-		// 龍の場合は斜め1マスも可能
-		if piece.Type == PromotedRook {
-			dirs = [][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
-			for _, d := range dirs {
-				nr, nc := row+d[0], col+d[1]
-				if b.isValidMove(row, col, nr, nc) {
-					moves = append(moves, Move{row, col, nr, nc, false, Empty, false})
-				}
+			if b.Cells[nr][nc].Owner != None {
+				break
 			}
 		}
+	}
 
-	case Pawn:
-		// 前進のみ
-		dir := 1
-		if piece.Owner == Second {
-			dir = 1
-		} else {
-			dir = -1
-		}
-		nr := row + dir
-		if b.isValidMove(row, col, nr, col) {
-			move := Move{row, col, nr, col, false, Empty, false}
-			if b.canPromote(piece.Owner, nr) {
-				moves = append(moves, Move{row, col, nr, col, false, Empty, true})
-			}
-			moves = append(moves, move)
+	// 1マスの動き
+	for _, d := range rules.Steps(piece) {
+		nr, nc := row+d[0], col+d[1]
+		if b.isValidMove(row, col, nr, nc) && !emit(nr, nc) {
+			return false
 		}
 	}
 
-	return moves
+	return true
 }
 
 // 持ち駒を打つ手を取得
 func (b *Board) GetDropMoves() []Move {
 	moves := []Move{}
+	b.forEachDropMove(func(m Move) bool {
+		moves = append(moves, m)
+		return true
+	})
+	return moves
+}
+
+// 持ち駒を打つ手を1手ずつyieldに渡す
+func (b *Board) forEachDropMove(yield func(Move) bool) bool {
+	rules := b.rules()
+	if !rules.Drops() {
+		return true
+	}
 	hand := b.FirstHand
 	if b.CurrentTurn == Second {
 		hand = b.SecondHand
 	}
 
-	// 重複を除く
-	uniquePieces := make(map[PieceType]bool)
+	// 重複を除く（探索の結果が毎回同じになるように駒の種類の順に打つ）
+	var inHand [PromotedPawn + 1]bool
 	for _, p := range hand {
-		uniquePieces[p] = true
+		inHand[p] = true
 	}
 
-	for pType := range uniquePieces {
-		for r := 0; r < 5; r++ {
-			for c := 0; c < 5; c++ {
+	for pType := Empty; pType <= PromotedPawn; pType++ {
+		if !inHand[pType] {
+			continue
+		}
+		for r := 0; r < b.Size(); r++ {
+			for c := 0; c < b.Size(); c++ {
 				if b.Cells[r][c].Owner == None {
-					// 歩の二歩チェック
-					if pType == Pawn && b.hasPawnInColumn(c, b.CurrentTurn) {
+					// 二歩や行き所のない駒など、ルールで打てないマス
+					if rules.DropError(b, pType, r, c) != nil {
 						continue
 					}
-					// 行き所のない駒チェック
-					if pType == Pawn {
-						if (b.CurrentTurn == First && r == 0) || (b.CurrentTurn == Second && r == 4) {
-							continue
-						}
+					if !yield(Move{-1, -1, r, c, true, pType, false}) {
+						return false
 					}
-					moves = append(moves, Move{-1, -1, r, c, true, pType, false})
 				}
 			}
 		}
 	}
 
-	return moves
+	return true
 }
 
-// 全ての合法手を取得
+// 全ての合法手を取得（自玉を取られる手と打ち歩詰めを除く）
 func (b *Board) GetAllLegalMoves() []Move {
+	return b.legalMoves(true)
+}
+
+// 疑似合法手を取得（駒の動きと打つ場所の制限だけを満たし、自玉が取られるかどうかは考えない）
+// 探索ではこちらを使い、指した後で IsLegal 相当の判定を行う。
+func (b *Board) GeneratePseudoLegal() []Move {
 	moves := []Move{}
+	b.forEachPseudoLegalMove(func(m Move) bool {
+		moves = append(moves, m)
+		return true
+	})
+	return moves
+}
 
+// 駒の動きだけを満たす手を1手ずつyieldに渡す
+func (b *Board) forEachPseudoLegalMove(yield func(Move) bool) bool {
 	// 盤上の駒の移動
-	for r := 0; r < 5; r++ {
-		for c := 0; c < 5; c++ {
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
 			if b.Cells[r][c].Owner == b.CurrentTurn {
-				moves = append(moves, b.GetPossibleMoves(r, c)...)
+				if !b.forEachPieceMove(r, c, yield) {
+					return false
+				}
 			}
 		}
 	}
 
 	// 持ち駒を打つ
-	moves = append(moves, b.GetDropMoves()...)
+	return b.forEachDropMove(yield)
+}
+
+// 合法手を1手ずつfに渡す（fがfalseを返したらそこで打ち切る）
+// スライスを作らないので、最初の数手で済む判定に向いている。
+func (b *Board) ForEachLegalMove(f func(Move) bool) {
+	b.forEachLegalMove(true, f)
+}
 
+func (b *Board) forEachLegalMove(checkUchifuzume bool, yield func(Move) bool) bool {
+	return b.forEachPseudoLegalMove(func(m Move) bool {
+		if b.leavesKingInCheck(m) {
+			return true
+		}
+		if checkUchifuzume && b.isUchifuzume(m) {
+			return true
+		}
+		return yield(m)
+	})
+}
+
+// 合法手を取得（打ち歩詰めの判定中は、応手側の打ち歩詰めまでは調べない）
+func (b *Board) legalMoves(checkUchifuzume bool) []Move {
+	moves := []Move{}
+	b.forEachLegalMove(checkUchifuzume, func(m Move) bool {
+		moves = append(moves, m)
+		return true
+	})
 	return moves
 }
 
-// 移動実行
-func (b *Board) MakeMove(move Move) bool {
+// 合法手が1つでもあるか
+func (b *Board) hasLegalMove(checkUchifuzume bool) bool {
+	found := false
+	b.forEachLegalMove(checkUchifuzume, func(Move) bool {
+		found = true
+		return false
+	})
+	return found
+}
+
+// 疑似合法手が合法手か（自玉が取られる手と打ち歩詰めでないか）
+func (b *Board) IsLegal(m Move) bool {
+	return !b.leavesKingInCheck(m) && !b.isUchifuzume(m)
+}
+
+// 指した後に自玉が取られる状態になるか
+func (b *Board) leavesKingInCheck(m Move) bool {
+	next := b.Clone()
+	next.ApplyLegal(m)
+	return next.kingThreatened(b.CurrentTurn)
+}
+
+// 打ち歩詰めか
+func (b *Board) isUchifuzume(m Move) bool {
+	if !m.IsDrop || m.DropPiece != Pawn {
+		return false
+	}
+	next := b.Clone()
+	next.ApplyLegal(m)
+	if !next.kingThreatened(next.CurrentTurn) {
+		return false
+	}
+	return !next.hasLegalMove(false)
+}
+
+// 移動実行（合法手かどうかを検証し、指せない手ならエラーを返して盤面を変更しない）
+func (b *Board) MakeMove(move Move) error {
+	if err := b.ValidateMove(move); err != nil {
+		return err
+	}
+	b.ApplyLegal(move)
+	return nil
+}
+
+// 指し手の検証
+func (b *Board) ValidateMove(move Move) error {
+	fail := func(err error) error {
+		return &IllegalMoveError{move, err}
+	}
+
+	if !b.isInBoard(move.ToRow, move.ToCol) {
+		return fail(ErrOutOfBoard)
+	}
+	if move.IsDrop {
+		if !b.rules().Drops() {
+			return fail(ErrNoDrops)
+		}
+		hand := b.FirstHand
+		if b.CurrentTurn == Second {
+			hand = b.SecondHand
+		}
+		found := false
+		for _, p := range hand {
+			if p == move.DropPiece {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail(ErrNotInHand)
+		}
+		if b.Cells[move.ToRow][move.ToCol].Owner != None {
+			return fail(ErrDropOnOccupied)
+		}
+		if err := b.rules().DropError(b, move.DropPiece, move.ToRow, move.ToCol); err != nil {
+			return fail(err)
+		}
+	} else {
+		if !b.isInBoard(move.FromRow, move.FromCol) {
+			return fail(ErrOutOfBoard)
+		}
+		piece := b.Cells[move.FromRow][move.FromCol]
+		if piece.Owner == None {
+			return fail(ErrNoPiece)
+		}
+		if piece.Owner != b.CurrentTurn {
+			return fail(ErrNotYourPiece)
+		}
+		if !move.Promote && b.rules().MustPromote(piece, move.ToRow) {
+			return fail(ErrDeadPiece)
+		}
+	}
+
+	found := false
+	for _, pm := range b.GeneratePseudoLegal() {
+		if movesEqual(&move, &pm) {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fail(ErrCannotMove)
+	}
+	if b.leavesKingInCheck(move) {
+		return fail(ErrLeavesKingInCheck)
+	}
+	if b.isUchifuzume(move) {
+		return fail(ErrUchifuzume)
+	}
+	return nil
+}
+
+// 検証せずに移動を実行（探索など、合法手であることが分かっている場合に使う）
+func (b *Board) ApplyLegal(move Move) {
 	if move.IsDrop {
 		// 持ち駒を打つ
 		b.Cells[move.ToRow][move.ToCol] = Piece{move.DropPiece, b.CurrentTurn}
@@ -369,20 +519,10 @@ func (b *Board) MakeMove(move Move) bool {
 		piece := b.Cells[move.FromRow][move.FromCol]
 		captured := b.Cells[move.ToRow][move.ToCol]
 
-		// 駒を取る
-		if captured.Owner != None {
-			capturedType := captured.Type
+		// 駒を取る（持ち駒なしのルールでは盤から除くだけ）
+		if captured.Owner != None && b.rules().Drops() {
 			// 成り駒は元に戻す
-			switch capturedType {
-			case PromotedSilver:
-				capturedType = Silver
-			case PromotedBishop:
-				capturedType = Bishop
-			case PromotedRook:
-				capturedType = Rook
-			case PromotedPawn:
-				capturedType = Pawn
-			}
+			capturedType := baseType(captured.Type)
 
 			if b.CurrentTurn == First {
 				b.FirstHand = append(b.FirstHand, capturedType)
@@ -393,16 +533,7 @@ func (b *Board) MakeMove(move Move) bool {
 
 		// 成り
 		if move.Promote {
-			switch piece.Type {
-			case Silver:
-				piece.Type = PromotedSilver
-			case Bishop:
-				piece.Type = PromotedBishop
-			case Rook:
-				piece.Type = PromotedRook
-			case Pawn:
-				piece.Type = PromotedPawn
-			}
+			piece.Type = promotedType(piece.Type)
 		}
 
 		b.Cells[move.ToRow][move.ToCol] = piece
@@ -415,13 +546,11 @@ func (b *Board) MakeMove(move Move) bool {
 	} else {
 		b.CurrentTurn = First
 	}
-
-	return true
 }
 
 // ヘルパー関数
 func (b *Board) isInBoard(row, col int) bool {
-	return row >= 0 && row < 5 && col >= 0 && col < 5
+	return row >= 0 && row < len(b.Cells) && col >= 0 && col < len(b.Cells)
 }
 
 func (b *Board) isValidMove(fromRow, fromCol, toRow, toCol int) bool {
@@ -433,29 +562,38 @@ func (b *Board) isValidMove(fromRow, fromCol, toRow, toCol int) bool {
 	return target.Owner != piece.Owner
 }
 
-func (b *Board) canPromote(player Player, row int) bool {
-	if player == First {
-		return row <= 0
-	}
-	return row >= 4
-}
-
-func (b *Board) getGoldMoves(player Player) [][2]int {
-	if player == First {
-		return [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, 0}}
+// 成った後の駒の種類
+func promotedType(pType PieceType) PieceType {
+	switch pType {
+	case Silver:
+		return PromotedSilver
+	case Bishop:
+		return PromotedBishop
+	case Rook:
+		return PromotedRook
+	case Pawn:
+		return PromotedPawn
 	}
-	return [][2]int{{1, -1}, {1, 0}, {1, 1}, {0, -1}, {0, 1}, {-1, 0}}
+	return pType
 }
 
-func (b *Board) getSilverMoves(player Player) [][2]int {
-	if player == First {
-		return [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {1, -1}, {1, 1}}
+// 成る前の駒の種類
+func baseType(pType PieceType) PieceType {
+	switch pType {
+	case PromotedSilver:
+		return Silver
+	case PromotedBishop:
+		return Bishop
+	case PromotedRook:
+		return Rook
+	case PromotedPawn:
+		return Pawn
 	}
-	return [][2]int{{1, -1}, {1, 0}, {1, 1}, {-1, -1}, {-1, 1}}
+	return pType
 }
 
 func (b *Board) hasPawnInColumn(col int, player Player) bool {
-	for r := 0; r < 5; r++ {
+	for r := 0; r < b.Size(); r++ {
 		if b.Cells[r][col].Owner == player && b.Cells[r][col].Type == Pawn {
 			return true
 		}
@@ -463,137 +601,79 @@ func (b *Board) hasPawnInColumn(col int, player Player) bool {
 	return false
 }
 
-// 勝敗判定
-func (b *Board) IsGameOver() (bool, Player) {
-	// 玉が取られたかチェック
-	firstKing, secondKing := false, false
-	for r := 0; r < 5; r++ {
-		for c := 0; c < 5; c++ {
-			if b.Cells[r][c].Type == King {
-				if b.Cells[r][c].Owner == First {
-					firstKing = true
-				} else if b.Cells[r][c].Owner == Second {
-					secondKing = true
-				}
+// 玉に相手の駒の利きがあるか（王手されているか）
+func (b *Board) kingThreatened(player Player) bool {
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			if b.Cells[r][c].Type == King && b.Cells[r][c].Owner == player {
+				return b.IsAttacked(r, c, player.Opponent())
 			}
 		}
 	}
+	return false
+}
 
-	if !firstKing {
-		return true, Second
-	}
-	if !secondKing {
-		return true, First
+// 勝敗判定
+func (b *Board) IsGameOver() (bool, Player) {
+	if c, winner := b.winCondition(); c != nil {
+		return true, winner
 	}
+	return b.rules().Winner(b)
+}
 
-	// TODO: 詰みチェック（簡易版では省略）
-
-	return false, None
+// 満たしている詰み以外の勝ちの条件と勝者（なければnil）
+func (b *Board) winCondition() (WinCondition, Player) {
+	for _, c := range b.rules().WinConditions() {
+		if won, winner := c.Check(b); won {
+			return c, winner
+		}
+	}
+	return nil, None
 }
 
 // AI: 評価関数
 func (b *Board) Evaluate() int {
+	return b.evaluateWith(evalParams)
+}
+
+// 評価パラメータpで評価する
+func (b *Board) evaluateWith(p *EvalParams) int {
 	score := 0
-	pieceValues := map[PieceType]int{
-		King:           10000,
-		Gold:           600,
-		Silver:         500,
-		Bishop:         800,
-		Rook:           900,
-		Pawn:           100,
-		PromotedSilver: 600,
-		PromotedBishop: 1000,
-		PromotedRook:   1100,
-		PromotedPawn:   600,
-	}
 
 	// 盤上の駒
-	for r := 0; r < 5; r++ {
-		for c := 0; c < 5; c++ {
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
 			piece := b.Cells[r][c]
 			if piece.Owner == First {
-				score += pieceValues[piece.Type]
+				score += p.pieceValue(piece.Type)
 			} else if piece.Owner == Second {
-				score -= pieceValues[piece.Type]
+				score -= p.pieceValue(piece.Type)
 			}
 		}
 	}
 
-	// 持ち駒
-	for _, p := range b.FirstHand {
-		score += pieceValues[p] * 8 / 10
-	}
-	for _, p := range b.SecondHand {
-		score -= pieceValues[p] * 8 / 10
+	// 持ち駒（持ち駒なしのルールでは使えないので数えない）
+	if b.rules().Drops() {
+		for _, h := range b.FirstHand {
+			score += p.handValue(h)
+		}
+		for _, h := range b.SecondHand {
+			score -= p.handValue(h)
+		}
 	}
 
 	return score
 }
 
-// AI: ミニマックス法
-func (b *Board) Minimax(depth int, alpha, beta int, maximizing bool) (int, *Move) {
-	if depth == 0 {
-		return b.Evaluate(), nil
-	}
-
-	gameOver, _ := b.IsGameOver()
-	if gameOver {
-		return b.Evaluate(), nil
-	}
-
-	moves := b.GetAllLegalMoves()
-	if len(moves) == 0 {
-		return b.Evaluate(), nil
-	}
-
-	var bestMove *Move
-	if maximizing {
-		maxEval := -999999
-		for _, move := range moves {
-			// コピーを作成
-			newBoard := *b
-			newBoard.FirstHand = append([]PieceType{}, b.FirstHand...)
-			newBoard.SecondHand = append([]PieceType{}, b.SecondHand...)
-
-			newBoard.MakeMove(move)
-			eval, _ := newBoard.Minimax(depth-1, alpha, beta, false)
-
-			if eval > maxEval {
-				maxEval = eval
-				moveCopy := move
-				bestMove = &moveCopy
-			}
-
-			alpha = max(alpha, eval)
-			if beta <= alpha {
-				break
-			}
-		}
-		return maxEval, bestMove
-	} else {
-		minEval := 999999
-		for _, move := range moves {
-			// コピーを作成
-			newBoard := *b
-			newBoard.FirstHand = append([]PieceType{}, b.FirstHand...)
-			newBoard.SecondHand = append([]PieceType{}, b.SecondHand...)
-
-			newBoard.MakeMove(move)
-			eval, _ := newBoard.Minimax(depth-1, alpha, beta, true)
-
-			if eval < minEval {
-				minEval = eval
-				moveCopy := move
-				bestMove = &moveCopy
-			}
+// 詰みの評価値
+const mateScore = 100000
 
-			beta = min(beta, eval)
-			if beta <= alpha {
-				break
-			}
-		}
-		return minEval, bestMove
-	}
+// AI: ミニマックス法
+// 疑似合法手を順に試し、指した後で自玉が取られる手はその場で除外する（遅延合法性チェック）。
+// ctxがキャンセルされると探索を打ち切る（その場合の結果は使えない）。
+func (b *Board) Minimax(ctx context.Context, depth int, alpha, beta int, maximizing bool) (int, *Move) {
+	s := &searcher{ctx: ctx, root: b.CurrentTurn}
+	return s.minimax(b, depth, alpha, beta, maximizing)
 }
 
 func max(a, b int) int {
@@ -612,149 +692,304 @@ func min(a, b int) int {
 
 // AIの手を取得
 func (b *Board) GetAIMove() *Move {
-	depth := 3 // 探索深度
-	_, move := b.Minimax(depth, -999999, 999999, b.CurrentTurn == First)
+	return b.GetAIMoveContext(context.Background())
+}
+
+// AIの手を取得（ctxがキャンセルされたら探索を中断してnilを返す）
+func (b *Board) GetAIMoveContext(ctx context.Context) *Move {
+	move := b.Search(ctx, SearchOptions{Depth: defaultDepth})
+	if ctx.Err() != nil {
+		return nil
+	}
 	return move
 }
 
-// メインゲームループ
+// エントリポイント
 func main() {
-	rand.Seed(time.Now().UnixNano())
-	scanner := bufio.NewScanner(os.Stdin)
-
-	fmt.Println("=== ミニ将棋（5五将棋）===")
-	fmt.Println("1: 先手（人間） vs 後手（AI）")
-	fmt.Println("2: 先手（AI） vs 後手（人間）")
-	fmt.Print("選択してください: ")
-
-	scanner.Scan()
-	mode, _ := strconv.Atoi(scanner.Text())
-
-	board := NewBoard()
-	aiPlayer := Second
-	if mode == 2 {
-		aiPlayer = First
+	setLanguage(languageFromEnv())
+	if path, err := themesPath(); err == nil {
+		if err := loadThemes(path); err != nil {
+			fmt.Fprintf(os.Stderr, tr("テーマの設定ファイルを読み込めません（組み込みのテーマを使います）: %s: %v")+"\n", path, err)
+		}
 	}
-
-	for {
-		board.Display()
-
-		gameOver, winner := board.IsGameOver()
-		if gameOver {
-			if winner == First {
-				fmt.Println("\n先手の勝ちです！")
-			} else {
-				fmt.Println("\n後手の勝ちです！")
-			}
-			break
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			os.Exit(cmd(os.Args[2:]))
 		}
+	}
 
-		if board.CurrentTurn == First {
-			fmt.Println("\n先手の番です")
-		} else {
-			fmt.Println("\n後手の番です")
-		}
-
-		var move *Move
-
-		if board.CurrentTurn == aiPlayer {
-			fmt.Println("AIが考えています...")
-			move = board.GetAIMove()
-			if move != nil {
-				if move.IsDrop {
-					piece := Piece{Type: move.DropPiece, Owner: First}
-					fmt.Printf("AI: %sを%d%sに打つ\n",
-						strings.TrimSpace(piece.String()),
-						move.ToCol+1,
-						[]string{"一", "二", "三", "四", "五"}[move.ToRow])
-				} else {
-					fmt.Printf("AI: %d%sから%d%sへ",
-						move.FromCol+1,
-						[]string{"一", "二", "三", "四", "五"}[move.FromRow],
-						move.ToCol+1,
-						[]string{"一", "二", "三", "四", "五"}[move.ToRow])
-					if move.Promote {
-						fmt.Print("（成）")
-					}
-					fmt.Println()
-				}
-			}
-		} else {
-			// 人間の入力
-			fmt.Println("移動: 5133 のように入力（51から33へ）")
-			fmt.Println("持ち駒: p53 のように入力（p=歩,s=銀,g=金,b=角,r=飛を53に打つ）")
-			fmt.Print("入力: ")
-
-			scanner.Scan()
-			input := scanner.Text()
-
-			move = parseInput(input, board)
-			if move == nil {
-				fmt.Println("無効な入力です")
-				continue
-			}
+	lang := flag.String("lang", "", "表示する言語（%s、または訳を書いたJSONファイル。省略すると環境変数LANGから決める）")
+	themeName := flag.String("theme", "default", "盤面のテーマ (%s)")
+	flag.BoolVar(&useLetters, "letters", false, "駒をアルファベットで表示する")
+	loadFile := flag.String("load", "", "CSA形式の棋譜を読み込んで続きから対局する")
+	saveFile := flag.String("save", "", "終局後に棋譜をCSA形式で保存する")
+	bodFile := flag.String("bod", "", "BOD形式の盤面図を開始局面として読み込む")
+	noDrops := flag.Bool("nodrops", false, "持ち駒なしの変則ルール（取った駒は盤から除かれる）")
+	zone := flag.Int("zone", 1, "敵陣の段数（1か2）")
+	shuffle := flag.Bool("shuffle", false, "駒の初期配置をランダムにする（先手と後手は点対称）")
+	timeLimit := flag.Duration("time", 0, "各対局者の持ち時間（例: 5m。0なら無制限）")
+	nodes := flag.Int("nodes", 0, "AIが1手に探索する局面の数の上限（0なら制限しない。同じ局面なら毎回同じ手を指す）")
+	threads := flag.Int("threads", 1, "AIが初手を分担して読むゴルーチンの数（結果は1のときと同じで、速くなる）")
+	bookFile := flag.String("book", "", "AIが定跡ファイルの手を指す（定跡にない局面では読んで指す）")
+	try := flag.Bool("try", false, "トライルール（玉が相手の一段目に入り、取られなければ勝ち）")
+	resign := flag.Int("resign", 0, "AIが投了する評価値（AIから見てこの値だけ不利な評価が続いたら投了する。0なら投了しない）")
+	resignMoves := flag.Int("resign-moves", 3, "AIが投了するまでに、-resign の基準を下回る評価が続く手数")
+	contempt := flag.Int("contempt", 0, "AIが千日手を嫌う度合い（正なら避け、負なら歓迎する。歩1枚=100）")
+	evalFile := flag.String("eval-file", "", "評価関数のパラメータをJSONファイルから読み込む")
+	training := flag.Bool("training", false, "練習モード（指した手を最善手と比べて表示する）")
+	flip := flag.Bool("flip", false, "後手で指すときに盤面と座標を後手側から見た向きにする")
+	quiet := flag.Bool("quiet", false, "指し手と結果だけを表示する（盤面や案内は表示しない）")
+	verbose := flag.Bool("verbose", false, "AIの探索の詳細と消費時間を表示する")
+	noBoard := flag.Bool("no-board", false, "盤面を表示しない")
+	jsonOutput := flag.Bool("json", false, "盤面・指し手・エラー・結果を1行ごとのJSONで出力する")
+	coach := flag.Bool("coach", false, "コーチモード（指す前に取られそうな駒と相手の狙いを表示する）")
+	hostSocket := flag.String("host", "", "Unixドメインソケットを作って相手の接続を待つ（自分が先手）")
+	joinSocket := flag.String("join", "", "相手が作ったUnixドメインソケットに接続する（自分が後手）")
+	moves := flag.String("moves", "", "指し手を空白区切りで並べ、入力を待たずに先手・後手とも順に指す（\"-\" なら標準入力から読む）")
+	engineLog := flag.String("engine-log", "", "AIの探索の記録をファイルに追記する")
+	botCmd := flag.String("bot-cmd", "", "人間の代わりに外部のプログラムのボットを指させる（bot パッケージの Run で作ったプログラムのコマンド）")
+	botName := flag.String("bot", "", "人間の代わりにボットを指させる（%s）")
+	matchFile := flag.String("match", "", "AI同士の対局で、先手と後手のAIの設定をJSONファイルから読み込む")
+	modeFlag := flag.String("mode", "", "開始メニューを表示せずに始めるモード（%s、またはメニューの番号）")
+	// 引数の誤りも対局結果（0〜2）と区別できる終了コードにする
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	flag.Usage = func() {
+		// -h より前の -lang は、フラグの説明にも使う
+		if *lang != "" {
+			setLanguage(*lang)
+		}
+		fmt.Fprintf(flag.CommandLine.Output(), tr("使い方: %s [オプション]")+"\n", os.Args[0])
+		printDefaults(flag.CommandLine, map[string]string{
+			"lang":  strings.Join(languageNames(), ", "),
+			"theme": strings.Join(themeNames(), ", "),
+			"bot":   strings.Join(botNames(), ", "),
+			"mode":  strings.Join(modeNames[1:], ", "),
+		})
+	}
+	if err := flag.CommandLine.Parse(os.Args[1:]); err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		os.Exit(exitUsage)
+	}
 
-			// 合法手チェック
-			legalMoves := board.GetAllLegalMoves()
-			found := false
-			for _, lm := range legalMoves {
-				if movesEqual(move, &lm) {
-					move = &lm
-					found = true
-					break
-				}
-			}
+	if *lang != "" {
+		if err := setLanguage(*lang); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+	}
+	mode, err := parseMode(*modeFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
+	if *matchFile != "" && (mode != ModeNone && mode != ModeSelfPlay || *moves != "" || *hostSocket != "" || *joinSocket != "") {
+		fmt.Fprintln(os.Stderr, tr("-match はAI同士の対局でのみ使えます"))
+		os.Exit(exitUsage)
+	}
+	bot, ok := bots[*botName]
+	if *botName != "" && !ok {
+		fmt.Fprintf(os.Stderr, tr("ボットの名前が不正です: %s（%s）")+"\n", *botName, strings.Join(botNames(), ", "))
+		os.Exit(exitUsage)
+	}
+	if (*botName != "" || *botCmd != "") && (*moves != "" || *matchFile != "") {
+		fmt.Fprintln(os.Stderr, tr("-bot・-bot-cmd は -moves・-match と同時に指定できません"))
+		os.Exit(exitUsage)
+	}
+	if *botName != "" && *botCmd != "" {
+		fmt.Fprintln(os.Stderr, tr("-bot と -bot-cmd は同時に指定できません"))
+		os.Exit(exitUsage)
+	}
+	if mode != ModeNone && (*moves != "" || *hostSocket != "" || *joinSocket != "") {
+		fmt.Fprintln(os.Stderr, tr("-mode は -moves・-host・-join と同時に指定できません"))
+		os.Exit(exitUsage)
+	}
+	if *nodes < 0 {
+		fmt.Fprintln(os.Stderr, tr("局面の数の上限は0以上です:"), *nodes)
+		os.Exit(exitUsage)
+	}
+	if *resign < 0 || *resignMoves < 1 {
+		fmt.Fprintln(os.Stderr, tr("-resign は0以上、-resign-moves は1以上です"))
+		os.Exit(exitUsage)
+	}
+	if *quiet && *verbose {
+		fmt.Fprintln(os.Stderr, tr("-quiet と -verbose は同時に指定できません"))
+		os.Exit(exitUsage)
+	}
+	if err := setTheme(*themeName); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitUsage)
+	}
 
-			if !found {
-				// 成りの選択がある場合
-				if !move.IsDrop && canChoosePromote(board, move) {
-					fmt.Print("成りますか？ (y/n): ")
-					scanner.Scan()
-					if scanner.Text() == "y" {
-						move.Promote = true
-					}
+	if *evalFile != "" {
+		p, err := loadEvalParams(*evalFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("評価パラメータを読み込めません:"), err)
+			os.Exit(exitError)
+		}
+		evalParams = p
+	}
 
-					// 再度チェック
-					for _, lm := range legalMoves {
-						if movesEqual(move, &lm) {
-							move = &lm
-							found = true
-							break
-						}
-					}
-				}
+	rand.Seed(time.Now().UnixNano())
 
-				if !found {
-					fmt.Println("その手は指せません")
-					continue
-				}
-			}
+	game := NewGame(os.Stdin, os.Stdout, systemTime{})
+	game.SaveFile = *saveFile
+	game.Contempt = *contempt
+	game.Nodes = *nodes
+	game.Threads = *threads
+	game.Resign, game.ResignMoves = *resign, *resignMoves
+	if *bookFile != "" {
+		bk, err := loadBook(*bookFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("定跡を読み込めません:"), err)
+			os.Exit(exitError)
+		}
+		if len(bk.Positions) == 0 {
+			fmt.Fprintln(os.Stderr, tr("定跡がありません:"), *bookFile)
+			os.Exit(exitError)
 		}
+		game.Book = bk
+	}
+	game.Training = *training
+	game.Coach = *coach
+	game.Flip = *flip
+	game.Quiet = *quiet
+	game.Verbose = *verbose
+	game.NoBoard = *noBoard
+	if *jsonOutput {
+		game.UseJSON(os.Stdout)
+	}
+	if *engineLog != "" {
+		f, err := os.OpenFile(*engineLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("探索の記録ファイルを開けません:"), err)
+			os.Exit(exitError)
+		}
+		defer f.Close()
+		game.EngineLog = f
+	}
+	if *shuffle {
+		game.SetPosition(NewShuffledBoard(rand.New(rand.NewSource(time.Now().UnixNano()))))
+	}
+	if *bodFile != "" {
+		b, err := loadBOD(*bodFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("盤面図を読み込めません:"), err)
+			os.Exit(exitError)
+		}
+		game.SetPosition(b)
+	}
+	if *zone < 1 || *zone > 2 {
+		fmt.Fprintln(os.Stderr, tr("敵陣の段数は1か2です:"), *zone)
+		os.Exit(exitUsage)
+	}
+	if *noDrops || *zone != 1 || *try {
+		b := game.Board.Clone()
+		b.Rules = Minishogi{NoDrops: *noDrops, ZoneRanks: *zone, Try: *try}
+		game.SetPosition(b)
+	}
+	if *loadFile != "" {
+		r, err := loadRecord(*loadFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("棋譜を読み込めません:"), err)
+			os.Exit(exitError)
+		}
+		game.Resume(r)
+	}
+	if *hostSocket != "" || *joinSocket != "" {
+		p, err := game.connectSocket(*hostSocket+*joinSocket, *hostSocket != "")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("相手と接続できません:"), err)
+			os.Exit(exitError)
+		}
+		defer p.Close()
+	}
+	if *moves != "" {
+		script, err := parseScript(*moves, os.Stdin)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("指し手を読み込めません:"), err)
+			os.Exit(exitError)
+		}
+		game.UseScript(script)
+	}
+	if *timeLimit > 0 {
+		game.UseClock(*timeLimit)
+	}
+	if *matchFile != "" {
+		m, err := loadMatchConfig(*matchFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("対局の設定を読み込めません:"), err)
+			os.Exit(exitError)
+		}
+		if err := game.UseMatch(m); err != nil {
+			fmt.Fprintln(os.Stderr, tr("対局の設定が不正です:"), err)
+			os.Exit(exitUsage)
+		}
+		mode = ModeNone
+	}
 
-		if move != nil {
-			board.MakeMove(*move)
+	handleInterrupt(game)
+	if mode == ModeNone && !game.modeSelected {
+		mode = game.Menu()
+	}
+	switch mode {
+	case ModeNone:
+		if !game.modeSelected {
+			return // メニューで入力が終わった
+		}
+	case ModeNetwork:
+		p, err := game.askRemote()
+		if err == errQuit {
+			return
+		} else if err != nil {
+			fmt.Fprintln(os.Stderr, tr("相手と接続できません:"), err)
+			os.Exit(exitError)
+		}
+		defer p.Close()
+	case ModeReplay:
+		os.Exit(game.askReplay())
+	case ModePuzzle:
+		os.Exit(runPuzzleInput(nil, game.remainingInput()))
+	default:
+		game.SetMode(mode)
+	}
+	if *botCmd != "" {
+		eb, err := startExternalBot(*botCmd)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("ボットのプログラムを起動できません:"), err)
+			os.Exit(exitError)
 		}
+		defer eb.Close()
+		bot, *botName = eb, eb.name
+	}
+	if bot != nil {
+		if err := game.UseBot(*botName, bot); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(exitUsage)
+		}
+	}
+	game.Run()
+	if game.Script != nil {
+		os.Exit(game.exitCode())
+	}
+	for game.Remote == nil && game.AskRematch() {
+		game.Run()
+	}
+	if game.SelfPlay {
+		os.Exit(game.exitCode())
 	}
 }
 
 // 入力パース（数字のみ版）
 func parseInput(input string, board *Board) *Move {
-	input = strings.TrimSpace(strings.ToLower(input))
+	input, promote, explicit := cutPromotion(strings.TrimSpace(strings.ToLower(input)))
 
 	// 持ち駒を打つ場合（例: p53, s42）
-	if len(input) == 3 && !isDigit(input[0]) {
-		pieces := map[byte]PieceType{
-			'p': Pawn,
-			's': Silver,
-			'g': Gold,
-			'b': Bishop,
-			'r': Rook,
-		}
-
-		if pType, ok := pieces[input[0]]; ok {
+	if len(input) == 3 && !isDigit(input[0]) && !explicit {
+		if pType, ok := dropPieceType(input[0]); ok {
 			col := int(input[1]-'0') - 1 // 1→0, 2→1, ..., 5→4
 			row := int(input[2]-'0') - 1 // 1→0, 2→1, ..., 5→4
-			if col >= 0 && col < 5 && row >= 0 && row < 5 {
+			if board.isInBoard(row, col) {
 				return &Move{-1, -1, row, col, true, pType, false}
 			}
 		}
@@ -767,15 +1002,60 @@ func parseInput(input string, board *Board) *Move {
 		toCol := int(input[2]-'0') - 1   // 1→0, 2→1, ..., 5→4
 		toRow := int(input[3]-'0') - 1   // 1→0, 2→1, ..., 5→4
 
-		if fromCol >= 0 && fromCol < 5 && fromRow >= 0 && fromRow < 5 &&
-			toCol >= 0 && toCol < 5 && toRow >= 0 && toRow < 5 {
-			return &Move{fromRow, fromCol, toRow, toCol, false, Empty, false}
+		if board.isInBoard(fromRow, fromCol) && board.isInBoard(toRow, toCol) {
+			return &Move{fromRow, fromCol, toRow, toCol, false, Empty, promote}
 		}
 	}
 
 	return nil
 }
 
+// 持ち駒を打つときに指定する駒の文字（表示する順）
+var dropLetters = []struct {
+	letter byte
+	pType  PieceType
+}{
+	{'p', Pawn},
+	{'s', Silver},
+	{'g', Gold},
+	{'b', Bishop},
+	{'r', Rook},
+}
+
+// 打つ駒の文字から駒の種類を求める
+func dropPieceType(letter byte) (PieceType, bool) {
+	for _, d := range dropLetters {
+		if d.letter == letter {
+			return d.pType, true
+		}
+	}
+	return Empty, false
+}
+
+// 成・不成の指定（例: 4142+、4142なる、4142=、4142ならず）
+// 「不成」は「成」より先に調べる。
+var promotionSuffixes = []struct {
+	text    string
+	promote bool
+}{
+	{"+", true},
+	{"=", false},
+	{"ならず", false},
+	{"不成", false},
+	{"なる", true},
+	{"成", true},
+}
+
+// 指し手の入力の末尾から成・不成の指定を取り除く（指定がなければexplicitはfalse）
+func cutPromotion(input string) (rest string, promote, explicit bool) {
+	for _, s := range promotionSuffixes {
+		if rest, ok := strings.CutSuffix(input, s.text); ok {
+			return rest, s.promote, true
+		}
+	}
+	return input, false, false
+}
+
 func isDigit(b byte) bool {
 	return b >= '0' && b <= '9'
 }
@@ -814,9 +1094,5 @@ func canChoosePromote(board *Board, move *Move) bool {
 	}
 
 	piece := board.Cells[move.FromRow][move.FromCol]
-	switch piece.Type {
-	case Silver, Bishop, Rook, Pawn:
-		return board.canPromote(piece.Owner, move.ToRow)
-	}
-	return false
+	return board.rules().CanPromote(piece, move.ToRow)
 }
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// AIの設定（AI同士の対局で手番ごとに変える）
+type EngineConfig struct {
+	Name     string `json:"name"`      // 対局者名（省略するとAI1・AI2）
+	Depth    int    `json:"depth"`     // 探索深度（省略すると既定の深さ）
+	Nodes    int    `json:"nodes"`     // 1手に探索する局面の数の上限（0なら制限しない）
+	Contempt *int   `json:"contempt"`  // 千日手を嫌う度合い（省略すると -contempt の値）
+	EvalFile string `json:"eval_file"` // 評価パラメータのJSONファイル（省略すると -eval-file の値）
+	Time     string `json:"time"`      // 持ち時間（例: 5m。省略すると -time の値）
+	Book     string `json:"book"`      // 定跡ファイル（省略すると -book の値）
+
+	eval  *EvalParams   // EvalFileから読み込んだ評価パラメータ
+	limit time.Duration // Timeを解釈した持ち時間
+	book  *Book         // Bookから読み込んだ定跡
+}
+
+// 対局の設定ファイル（先手と後手のAIの設定）
+type MatchConfig struct {
+	Sente EngineConfig `json:"sente"`
+	Gote  EngineConfig `json:"gote"`
+}
+
+// 対局の設定ファイルを読み込む（評価パラメータのファイルは設定ファイルのある場所から探す）
+func loadMatchConfig(path string) (*MatchConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var m MatchConfig
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	for _, e := range []*EngineConfig{&m.Sente, &m.Gote} {
+		if e.Depth < 0 || e.Nodes < 0 {
+			return nil, fmt.Errorf(tr("探索深度と局面の数の上限は0以上です: %s"), e.Name)
+		}
+		if e.Time != "" {
+			if e.limit, err = time.ParseDuration(e.Time); err != nil || e.limit <= 0 {
+				return nil, fmt.Errorf(tr("持ち時間が不正です: %s"), e.Time)
+			}
+		}
+		if e.EvalFile != "" {
+			if e.eval, err = loadEvalParams(configPath(path, e.EvalFile)); err != nil {
+				return nil, fmt.Errorf("%s: %w", e.EvalFile, err)
+			}
+		}
+		if e.Book != "" {
+			if e.book, err = loadBook(configPath(path, e.Book)); err != nil {
+				return nil, fmt.Errorf("%s: %w", e.Book, err)
+			}
+		}
+	}
+	return &m, nil
+}
+
+// 設定ファイルに書かれたファイルの場所（相対パスは設定ファイルのある場所から探す）
+func configPath(config, name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(filepath.Dir(config), name)
+}
+
+// 設定ファイルのAI同士で対局する（持ち時間を指定した側があれば、もう一方は -time の値を使う）
+func (g *Game) UseMatch(m *MatchConfig) error {
+	if m.Sente.limit > 0 || m.Gote.limit > 0 {
+		limits := g.clockLimits
+		if m.Sente.limit > 0 {
+			limits[First] = m.Sente.limit
+		}
+		if m.Gote.limit > 0 {
+			limits[Second] = m.Gote.limit
+		}
+		if limits[First] <= 0 || limits[Second] <= 0 {
+			return errors.New(tr("持ち時間は先手と後手の両方に指定します（-time で共通の持ち時間を指定できます）"))
+		}
+		g.useClocks(limits)
+	}
+	g.SetMode(ModeSelfPlay)
+	g.engines[First], g.engines[Second] = &m.Sente, &m.Gote
+	if m.Sente.Name != "" {
+		g.players[First] = m.Sente.Name
+	}
+	if m.Gote.Name != "" {
+		g.players[Second] = m.Gote.Name
+	}
+	g.setNames()
+	return nil
+}
+
+// 手番の側のAIの設定に合わせて探索の設定を変える（設定がなければそのまま）
+func (e *EngineConfig) apply(opts *SearchOptions) {
+	if e == nil {
+		return
+	}
+	if e.Depth > 0 {
+		opts.Depth = e.Depth
+	}
+	if e.Nodes > 0 {
+		opts.Nodes = e.Nodes
+	}
+	if e.Contempt != nil {
+		opts.Contempt = *e.Contempt
+	}
+	if e.eval != nil {
+		opts.Eval = e.eval
+	}
+}
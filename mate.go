@@ -0,0 +1,83 @@
+package main
+
+// 詰将棋の解図（攻め方は王手だけを続ける）
+
+// 王手になる合法手を順に返す
+func (b *Board) forEachCheck(yield func(Move, *Board) bool) {
+	b.ForEachLegalMove(func(m Move) bool {
+		next := b.Clone()
+		next.ApplyLegal(m)
+		if !next.InCheck() {
+			return true
+		}
+		return yield(m, next)
+	})
+}
+
+// 手番の側が plies 手以内（奇数）で詰ませられるか
+func (b *Board) IsMateIn(plies int) bool {
+	return b.MateIn(plies) != nil
+}
+
+// 手番の側が plies 手以内（奇数）で詰ませる初手（なければnil）
+func (b *Board) MateIn(plies int) *Move {
+	var mate *Move
+	b.forEachCheck(func(m Move, next *Board) bool {
+		if next.mated(plies - 1) {
+			mate = &m
+			return false
+		}
+		return true
+	})
+	return mate
+}
+
+// 指し手mで plies 手以内（mを含む、奇数）に詰むか
+func (b *Board) MatesWith(m Move, plies int) bool {
+	next := b.Clone()
+	next.ApplyLegal(m)
+	return next.InCheck() && next.mated(plies-1)
+}
+
+// plies 手以内（奇数）で詰ませる初手をすべて返す
+func (b *Board) MatingMoves(plies int) []Move {
+	var moves []Move
+	b.forEachCheck(func(m Move, next *Board) bool {
+		if next.mated(plies - 1) {
+			moves = append(moves, m)
+		}
+		return true
+	})
+	return moves
+}
+
+// 王手をかけられた側（手番）が、どう応じても plies 手以内に詰むか
+func (b *Board) mated(plies int) bool {
+	if !b.hasLegalMove(true) {
+		return true
+	}
+	if plies < 2 {
+		return false
+	}
+	escaped := false
+	b.ForEachLegalMove(func(m Move) bool {
+		next := b.Clone()
+		next.ApplyLegal(m)
+		if !next.IsMateIn(plies - 1) {
+			escaped = true
+			return false
+		}
+		return true
+	})
+	return !escaped
+}
+
+// 詰みまでの最短手数（limit手以内に詰まなければ0）
+func (b *Board) MateLength(limit int) int {
+	for n := 1; n <= limit; n += 2 {
+		if b.IsMateIn(n) {
+			return n
+		}
+	}
+	return 0
+}
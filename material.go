@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 駒得の表示で数える駒の種類（価値の高い順）
+var materialTypes = []PieceType{Rook, Bishop, Gold, Silver, Pawn}
+
+// 先手から見た駒の枚数の差（盤上の成り駒は元の駒として数え、持ち駒も含める）
+func (b *Board) materialDiff() map[PieceType]int {
+	diff := make(map[PieceType]int)
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			p := b.Cells[r][c]
+			switch p.Owner {
+			case First:
+				diff[baseType(p.Type)]++
+			case Second:
+				diff[baseType(p.Type)]--
+			}
+		}
+	}
+	for _, p := range b.FirstHand {
+		diff[p]++
+	}
+	for _, p := range b.SecondHand {
+		diff[p]--
+	}
+	return diff
+}
+
+// 駒得の1行（例: 駒得: 先手 +400（+銀 -歩））
+// 差は評価関数の駒の価値で数えるので、成り駒の分も含まれる。
+func (b *Board) materialText() string {
+	score := b.Evaluate()
+	if score == 0 {
+		return tr("駒得: なし")
+	}
+	leader, sign := tr("先手"), 1
+	if score < 0 {
+		leader, sign = tr("後手"), -1
+	}
+	diff := b.materialDiff()
+	var pieces []string
+	for _, pType := range materialTypes {
+		n := diff[pType] * sign
+		if n == 0 {
+			continue
+		}
+		text := "+" + currentTheme.pieceName(pType)
+		if n < 0 {
+			text, n = "-"+currentTheme.pieceName(pType), -n
+		}
+		if n > 1 {
+			text += fmt.Sprint(n)
+		}
+		pieces = append(pieces, text)
+	}
+	text := fmt.Sprintf(tr("駒得: %s %+d"), leader, score*sign)
+	if len(pieces) > 0 {
+		text += fmt.Sprintf(tr("（%s）"), strings.Join(pieces, " "))
+	}
+	return text
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 対局のモード（開始メニューの番号の順）
+type Mode int
+
+const (
+	ModeNone        Mode = iota // 未選択（メニューで入力が終わった）
+	ModeHumanFirst              // 先手（人間） vs 後手（AI）
+	ModeHumanSecond             // 先手（AI） vs 後手（人間）
+	ModeHotSeat                 // 人間 vs 人間（1台の端末で交互に入力）
+	ModeSelfPlay                // AI vs AI
+	ModeNetwork                 // ソケットでつないだ相手と対局
+	ModeReplay                  // 棋譜の再生
+	ModePuzzle                  // 今日の詰将棋
+)
+
+// -mode で指定するモードの名前（番号の順）
+var modeNames = []string{"", "sente", "gote", "hotseat", "selfplay", "network", "replay", "puzzle"}
+
+// メニューに表示するモードの説明
+func (m Mode) label() string {
+	switch m {
+	case ModeHumanFirst:
+		return tr("先手（人間） vs 後手（AI）")
+	case ModeHumanSecond:
+		return tr("先手（AI） vs 後手（人間）")
+	case ModeHotSeat:
+		return tr("人間 vs 人間")
+	case ModeSelfPlay:
+		return "AI vs AI"
+	case ModeNetwork:
+		return tr("通信対局（ソケット）")
+	case ModeReplay:
+		return tr("棋譜の再生")
+	case ModePuzzle:
+		return tr("今日の詰将棋")
+	}
+	return ""
+}
+
+// -mode の値（名前か番号）からモードを求める（空ならModeNone）
+func parseMode(s string) (Mode, error) {
+	if s == "" {
+		return ModeNone, nil
+	}
+	for i, name := range modeNames[1:] {
+		if s == name || s == strconv.Itoa(i+1) {
+			return Mode(i + 1), nil
+		}
+	}
+	return ModeNone, fmt.Errorf(tr("モードが不正です: %s（%s）"), s, strings.Join(modeNames[1:], ", "))
+}
+
+// 開始メニュー（正しい番号が入力されるまで尋ね直す。入力が終わればModeNone）
+func (g *Game) Menu() Mode {
+	w := g.chat()
+	fmt.Fprintln(w, tr("=== ミニ将棋（5五将棋）==="))
+	for m := ModeHumanFirst; m <= ModePuzzle; m++ {
+		fmt.Fprintf(w, "%d: %s\n", m, m.label())
+	}
+	for {
+		fmt.Fprint(w, tr("選択してください: "))
+		g.jsonPrompt("mode")
+		input, ok := g.readLineContext(g.base)
+		if !ok {
+			return ModeNone
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(input))
+		if err == nil && n >= int(ModeHumanFirst) && n <= int(ModePuzzle) {
+			return Mode(n)
+		}
+		g.printError("invalid_choice", fmt.Sprintf(tr("1〜%dの番号を入力してください"), ModePuzzle))
+	}
+}
+
+// 対局のモードを設定（人間とAIの組み合わせと対局者名）
+func (g *Game) SetMode(m Mode) {
+	g.AIPlayer, g.SelfPlay = Second, false
+	g.players[First], g.players[Second] = tr("人間"), "AI"
+	switch m {
+	case ModeHumanSecond:
+		g.AIPlayer = First
+		g.players[First], g.players[Second] = "AI", tr("人間")
+	case ModeHotSeat:
+		g.AIPlayer = None
+		g.players[First], g.players[Second] = tr("対局者1"), tr("対局者2")
+	case ModeSelfPlay:
+		g.AIPlayer, g.SelfPlay = None, true
+		g.players[First], g.players[Second] = "AI1", "AI2"
+	}
+	g.modeSelected = true
+	g.setNames()
+}
+
+// メニューで選んだ通信対局の相手と接続する（接続を待つか接続するかと、ソケットの場所を尋ねる）
+func (g *Game) askRemote() (*remotePeer, error) {
+	var host bool
+	for {
+		fmt.Fprint(g.chat(), tr("h: 相手の接続を待つ（先手）, j: 相手に接続する（後手）: "))
+		input, ok := g.readLineContext(g.base)
+		if !ok {
+			return nil, errQuit
+		}
+		if input = strings.TrimSpace(input); input == "h" || input == "j" {
+			host = input == "h"
+			break
+		}
+		g.printError("invalid_choice", tr("h か j を入力してください"))
+	}
+	fmt.Fprint(g.chat(), tr("ソケットの場所: "))
+	path, ok := g.readLineContext(g.base)
+	if !ok {
+		return nil, errQuit
+	}
+	return g.connectSocket(strings.TrimSpace(path), host)
+}
+
+// メニューで選んだ棋譜の再生（棋譜ファイルの場所を尋ねる）
+func (g *Game) askReplay() int {
+	fmt.Fprint(g.chat(), tr("棋譜ファイル: "))
+	path, ok := g.readLineContext(g.base)
+	if !ok {
+		return 0
+	}
+	return runReplayInput([]string{strings.TrimSpace(path)}, g.remainingInput())
+}
+
+// まだ読んでいない入力（メニューから対局以外のモードへ入力を引き継ぐ）
+func (g *Game) remainingInput() io.Reader {
+	g.startReader()
+	pr, pw := io.Pipe()
+	go func() {
+		for line := range g.lines {
+			if _, err := fmt.Fprintln(pw, line); err != nil {
+				return
+			}
+		}
+		pw.Close()
+	}()
+	return pr
+}
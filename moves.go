@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 指定したマスの駒が動ける先（相手の駒なら相手の手番として調べる）
+func (b *Board) PieceDestinations(row, col int) []Move {
+	p := b.Cells[row][col]
+	if p.Owner == None {
+		return nil
+	}
+	view := b
+	if p.Owner != b.CurrentTurn {
+		view = b.nullMove()
+	}
+	var moves []Move
+	for _, m := range view.GetPossibleMoves(row, col) {
+		if view.IsLegal(m) {
+			moves = append(moves, m)
+		}
+	}
+	return moves
+}
+
+// show コマンド: 指定したマスの駒が動ける先を盤面に重ねて表示
+// 動ける空きマスを「＊」、取れる駒を印「x」で示す。
+func (g *Game) printPieceMoves(w io.Writer, square string) {
+	square = strings.TrimSpace(square)
+	if len(square) != 2 || !isDigit(square[0]) || !isDigit(square[1]) {
+		g.printError("invalid_square", tr("マスは 53 のように入力してください"))
+		return
+	}
+	board := g.Board
+	row, col := int(square[1]-'1'), int(square[0]-'1')
+	if !board.isInBoard(row, col) {
+		g.printError(jsonErrorCodes[ErrOutOfBoard], tr(ErrOutOfBoard.Error()))
+		return
+	}
+	if g.flippedView() {
+		row, col = board.flipSquare(row, col)
+	}
+	p := board.Cells[row][col]
+	if p.Owner == None {
+		g.printError(jsonErrorCodes[ErrNoPiece], tr(ErrNoPiece.Error()))
+		return
+	}
+
+	targets := make(map[[2]int]bool)
+	for _, m := range board.PieceDestinations(row, col) {
+		targets[[2]int{m.ToRow, m.ToCol}] = true
+	}
+	fmt.Fprintf(w, "\n"+tr("%s%sの動ける先（＊: 動けるマス、x: 取れる駒）")+"\n",
+		g.squareText(row, col), currentTheme.pieceName(p.Type))
+	view := board
+	if g.flippedView() {
+		view = board.flipped()
+	}
+	view.displayCells(w, func(r, c int) string {
+		br, bc := r, c
+		if view != board {
+			br, bc = board.flipSquare(r, c)
+		}
+		cell := board.Cells[br][bc]
+		switch {
+		case targets[[2]int{br, bc}]:
+			return currentTheme.markedCell(cell, "x")
+		case br == row && bc == col:
+			return currentTheme.lastMoveCell(currentTheme.cell(cell))
+		}
+		return currentTheme.cell(cell)
+	})
+	if len(targets) == 0 {
+		fmt.Fprintln(w, tr("動ける先はありません"))
+	}
+}
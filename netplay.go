@@ -0,0 +1,296 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Unixドメインソケットでつないだ対局相手
+// 1行ずつのテキストで、挨拶（HELLO <版> <機能>）、変則ルール（RULES <名前>）、開始局面（START <SFEN>）、指し手（CSA形式）、
+// 投了（%TORYO）、中断（%CHUDAN）と、双方が対応していればチャット（CHAT <メッセージ>）をやり取りする。
+type remotePeer struct {
+	conn    net.Conn
+	lines   chan string
+	done    bool            // 相手が対局を中断した（以降は送らない）
+	version int             // 双方が対応している通信の版（低い方。挨拶のない古い相手なら0）
+	caps    map[string]bool // 双方が対応している機能
+	pending string          // 挨拶の代わりに受け取った行（次のrecvで返す）
+}
+
+// 通信の版（やり取りする行の形式を変えたら上げる）
+const protocolVersion = 1
+
+// この版で使える機能（挨拶で伝え、双方が対応しているものだけを使う）
+var protocolCapabilities = []string{"chat", "rules"}
+
+// 挨拶を送る（例: HELLO 1 chat。機能がなければ「-」）
+func (p *remotePeer) hello() error {
+	caps := strings.Join(protocolCapabilities, ",")
+	if caps == "" {
+		caps = "-"
+	}
+	return p.send(fmt.Sprintf("HELLO %d %s", protocolVersion, caps))
+}
+
+// 相手の挨拶を受け取り、使う版と機能を決める
+func (p *remotePeer) readHello() error {
+	line, ok := p.recv(context.Background())
+	if !ok {
+		return errors.New(tr("相手との接続が切れました"))
+	}
+	if strings.HasPrefix(line, "START ") {
+		// 挨拶を送らない古いプログラム（版0）: 機能は使わず、開始局面の行は対局の準備でそのまま読む
+		p.version, p.caps, p.pending = 0, map[string]bool{}, line
+		return nil
+	}
+	fields := strings.Fields(line)
+	if len(fields) != 3 || fields[0] != "HELLO" {
+		return fmt.Errorf(tr("相手の挨拶が不正です: %s"), line)
+	}
+	version, err := strconv.Atoi(fields[1])
+	if err != nil || version < 1 {
+		return fmt.Errorf(tr("相手の通信の版が不正です: %s"), fields[1])
+	}
+	p.version = min(version, protocolVersion)
+	p.caps = make(map[string]bool)
+	for _, c := range strings.Split(fields[2], ",") {
+		if slices.Contains(protocolCapabilities, c) {
+			p.caps[c] = true
+		}
+	}
+	return nil
+}
+
+// 双方が使える機能の一覧（表示用）
+func (p *remotePeer) capsText() string {
+	var names []string
+	for _, c := range protocolCapabilities {
+		if p.caps[c] {
+			names = append(names, c)
+		}
+	}
+	if len(names) == 0 {
+		return tr("なし")
+	}
+	return strings.Join(names, ", ")
+}
+
+func newRemotePeer(conn net.Conn) *remotePeer {
+	p := &remotePeer{conn: conn, lines: make(chan string)}
+	go func() {
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			p.lines <- strings.TrimRight(scanner.Text(), "\r")
+		}
+		close(p.lines)
+	}()
+	return p
+}
+
+// ソケットを作って相手の接続を待つ
+func hostRemote(path string) (*remotePeer, error) {
+	// 前回の対局で残ったソケットは削除する（ソケット以外のファイルは消さない）
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+	conn, err := ln.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return newRemotePeer(conn), nil
+}
+
+// 相手のソケットに接続
+func joinRemote(path string) (*remotePeer, error) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	return newRemotePeer(conn), nil
+}
+
+// 1行送る
+func (p *remotePeer) send(line string) error {
+	if p.done {
+		return nil
+	}
+	_, err := fmt.Fprintln(p.conn, line)
+	return err
+}
+
+// 1行受け取る（ctxがキャンセルされるか接続が切れるとfalse）
+func (p *remotePeer) recv(ctx context.Context) (string, bool) {
+	if line := p.pending; line != "" {
+		p.pending = ""
+		return line, true
+	}
+	select {
+	case line, ok := <-p.lines:
+		return line, ok
+	case <-ctx.Done():
+		return "", false
+	}
+}
+
+func (p *remotePeer) Close() error {
+	return p.conn.Close()
+}
+
+// ソケットの相手と対局する（sideは相手の手番。先手側が開始局面を送る）
+func (g *Game) Connect(p *remotePeer, side Player) error {
+	g.Remote, g.remoteSide = p, side
+	g.AIPlayer, g.SelfPlay = None, false
+	g.modeSelected = true
+	if side == Second {
+		// 接続を待った側（先手）から挨拶する
+		if err := p.hello(); err != nil {
+			return err
+		}
+		if err := p.readHello(); err != nil {
+			return err
+		}
+		// 変則ルールは、相手が対応していなければ対局できない
+		if name := g.Board.rules().Name(); name != "" {
+			if !p.caps["rules"] {
+				return fmt.Errorf(tr("相手のプログラムが変則ルールに対応していません: %s"), name)
+			}
+			if err := p.send("RULES " + name); err != nil {
+				return err
+			}
+		}
+		if err := p.send("START " + g.Board.SFEN(1)); err != nil {
+			return err
+		}
+	} else {
+		if err := p.readHello(); err != nil {
+			return err
+		}
+		// 版0の相手は挨拶を指し手と区別できないので送らない
+		if p.version > 0 {
+			if err := p.hello(); err != nil {
+				return err
+			}
+		}
+		line, ok := p.recv(context.Background())
+		var rules Rules = Minishogi{}
+		if name, found := strings.CutPrefix(line, "RULES "); ok && found && p.caps["rules"] {
+			r, err := ParseRules(name)
+			if err != nil {
+				return err
+			}
+			rules = r
+			line, ok = p.recv(context.Background())
+		}
+		sfen, found := strings.CutPrefix(line, "START ")
+		if !ok || !found {
+			return errors.New(tr("開始局面を受け取れません"))
+		}
+		b, err := ParseSFEN(sfen)
+		if err != nil {
+			return err
+		}
+		b.Rules = rules
+		if err := b.checkPosition(); err != nil {
+			return fmt.Errorf(tr("開始局面が不正です: %v"), err)
+		}
+		g.SetPosition(b)
+		if name := rules.Name(); name != "" {
+			fmt.Fprintf(g.chat(), tr("変則ルール: %s")+"\n", name)
+		}
+	}
+	g.players[First], g.players[Second] = tr("対局者1"), tr("対局者2")
+	g.setNames()
+	fmt.Fprintf(g.chat(), tr("相手と接続しました（通信の版 %d、使える機能: %s）")+"\n", p.version, p.capsText())
+	return nil
+}
+
+// 相手にチャットのメッセージを送る（双方が対応していなければエラーを表示する）
+func (g *Game) sendChat(text string) {
+	if g.Remote == nil || !g.Remote.caps["chat"] {
+		g.printError("chat_unavailable", tr("チャットは、相手もチャットに対応している通信対局でだけ使えます"))
+		return
+	}
+	g.sendRemote("CHAT " + text)
+}
+
+// ソケットで相手とつないで対局を準備する（hostなら接続を待って先手、そうでなければ接続して後手）
+func (g *Game) connectSocket(path string, host bool) (*remotePeer, error) {
+	var p *remotePeer
+	var err error
+	side := Second
+	if host {
+		fmt.Fprintln(g.chat(), tr("相手の接続を待っています:"), path)
+		p, err = hostRemote(path)
+	} else {
+		p, err = joinRemote(path)
+		side = First
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := g.Connect(p, side); err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// 相手に送る（相手がいなければ何もしない）
+func (g *Game) sendRemote(line string) {
+	if g.Remote == nil {
+		return
+	}
+	if err := g.Remote.send(line); err != nil {
+		g.printError("send_failed", tr("相手に送れません:"), err)
+	}
+}
+
+// 相手の指し手を受け取る（投了・接続切れ・不正な手なら結果を設定してnil）
+func (g *Game) readRemoteMove(ctx context.Context) *Move {
+	local := g.remoteSide.Opponent()
+	line, ok := g.Remote.recv(ctx)
+	for ok && g.Remote.caps["chat"] && strings.HasPrefix(line, "CHAT ") {
+		text := strings.TrimPrefix(line, "CHAT ")
+		g.printReply(jsonEvent{Type: "chat", Player: jsonPlayers[g.remoteSide], Message: text}, func(w io.Writer) {
+			fmt.Fprintf(w, tr("相手のメッセージ: %s")+"\n", text)
+		})
+		line, ok = g.Remote.recv(ctx)
+	}
+	if !ok {
+		if ctx.Err() == nil {
+			g.printError("disconnected", tr("相手との接続が切れました"))
+			g.Result = winResult(local, ReasonResign)
+		}
+		return nil
+	}
+	if line == "%CHUDAN" {
+		fmt.Fprintln(g.out, "\n"+tr("相手が対局を中断しました"))
+		g.Remote.done = true
+		g.Interrupt()
+		return nil
+	}
+	if line == "%TORYO" {
+		g.Result = winResult(local, ReasonResign)
+		return nil
+	}
+	move, err := parseCSAMove(g.Board, line)
+	if err != nil {
+		g.printError(errorCode(err, "illegal_move"), tr("相手から指せない手が届きました:"), err)
+		g.Result = winResult(local, ReasonIllegalMove)
+		return nil
+	}
+	return &move
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// 案内や途中経過の出力先（静かな表示では捨てる）
+func (g *Game) chat() io.Writer {
+	if g.Quiet {
+		return io.Discard
+	}
+	return g.out
+}
+
+// 手番の印（先手は▲、後手は△）
+func playerMark(player Player) string {
+	if player == Second {
+		return "△"
+	}
+	return "▲"
+}
+
+// 指した手を表示（静かな表示では手だけ、詳しい表示では消費時間も）
+func (g *Game) printPlayed(player Player, move *Move, elapsed time.Duration) {
+	switch {
+	case g.Quiet:
+		fmt.Fprintf(g.out, "%s%s\n", playerMark(player), g.moveText(move))
+	case g.Verbose:
+		fmt.Fprintf(g.out, tr("消費時間: %v")+"\n", elapsed.Round(time.Millisecond))
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import "fmt"
+
+// 盤を180度回した座標（後手から見た座標との変換。2回変換すると元に戻る）
+func (b *Board) flipSquare(row, col int) (int, int) {
+	n := b.Size() - 1
+	return n - row, n - col
+}
+
+// 指し手の座標を盤を180度回した座標にする
+func (b *Board) flipMove(m Move) Move {
+	m.ToRow, m.ToCol = b.flipSquare(m.ToRow, m.ToCol)
+	if !m.IsDrop {
+		m.FromRow, m.FromCol = b.flipSquare(m.FromRow, m.FromCol)
+	}
+	return m
+}
+
+// 盤を180度回した盤面（後手側から見た表示用）
+func (b *Board) flipped() *Board {
+	f := b.Clone()
+	for r := range b.Cells {
+		for c := range b.Cells[r] {
+			fr, fc := b.flipSquare(r, c)
+			f.Cells[fr][fc] = b.Cells[r][c]
+		}
+	}
+	return f
+}
+
+// 人間が後手で、盤面と座標を後手側から見せるか（ソケットの対局では後手側は常に後手から見せる）
+func (g *Game) flippedView() bool {
+	if g.Remote != nil {
+		return g.remoteSide == First
+	}
+	return g.Flip && g.AIPlayer == First
+}
+
+// 盤面を人間から見た向きで表示
+func (g *Game) display() {
+	if g.NoBoard || g.Quiet {
+		return
+	}
+	var last *Move
+	if n := len(g.Record.Moves); n > 0 {
+		last = &g.Record.Moves[n-1]
+	}
+	if g.flippedView() {
+		if last != nil {
+			m := g.Board.flipMove(*last)
+			last = &m
+		}
+		g.Board.flipped().DisplayMove(g.out, last)
+	} else {
+		g.Board.DisplayMove(g.out, last)
+	}
+	fmt.Fprintln(g.out, g.Board.materialText())
+}
+
+// 指し手を人間から見た座標で表示
+func (g *Game) moveText(move *Move) string {
+	if g.flippedView() {
+		m := g.Board.flipMove(*move)
+		move = &m
+	}
+	return moveText(move)
+}
+
+// マスを人間から見た座標で表示（例: 2三）
+func (g *Game) squareText(row, col int) string {
+	if g.flippedView() {
+		row, col = g.Board.flipSquare(row, col)
+	}
+	return fmt.Sprintf("%d%s", col+1, rankNames[row])
+}
@@ -0,0 +1,41 @@
+package main
+
+import "sync"
+
+// 探索で使い回す盤面と指し手のバッファ
+// 探索の1局面ごとに盤面のコピーと指し手のスライスを作るとGCが頻繁に動き、持ち時間のある探索の時間配分が乱れるため。
+var (
+	boardPool = sync.Pool{New: func() any { return &Board{} }}
+	movesPool = sync.Pool{New: func() any { return new([]Move) }}
+)
+
+// 盤面のコピーをプールから取得（使い終わったら putBoard で返す）
+func (b *Board) pooledClone() *Board {
+	c := boardPool.Get().(*Board)
+	if c.Size() != b.Size() {
+		c.Cells = makeCells(b.Size())
+	}
+	for r := range b.Cells {
+		copy(c.Cells[r], b.Cells[r])
+	}
+	c.FirstHand = append(c.FirstHand[:0], b.FirstHand...)
+	c.SecondHand = append(c.SecondHand[:0], b.SecondHand...)
+	c.CurrentTurn = b.CurrentTurn
+	c.Rules = b.Rules
+	return c
+}
+
+func putBoard(b *Board) {
+	boardPool.Put(b)
+}
+
+// 指し手のバッファをプールから取得（使い終わったら putMoves で返す）
+func getMoves() *[]Move {
+	buf := movesPool.Get().(*[]Move)
+	*buf = (*buf)[:0]
+	return buf
+}
+
+func putMoves(buf *[]Move) {
+	movesPool.Put(buf)
+}
@@ -0,0 +1,70 @@
+package main
+
+import "context"
+
+// 不変な局面
+// 生成後に内部の盤面を変更しないため、複数のゴルーチンから同時に参照・解析できる。
+type Position struct {
+	b *Board
+}
+
+// 盤面から局面を作成（盤面はコピーされるので、元の盤面を変更しても影響しない）
+func NewPosition(b *Board) Position {
+	return Position{b.Clone()}
+}
+
+// 盤面のコピーを取得
+func (p Position) Board() *Board {
+	return p.b.Clone()
+}
+
+// 手番
+func (p Position) Turn() Player {
+	return p.b.CurrentTurn
+}
+
+// 指定したマスの駒
+func (p Position) At(row, col int) Piece {
+	return p.b.Cells[row][col]
+}
+
+// 持ち駒のコピー
+func (p Position) Hand(player Player) []PieceType {
+	if player == Second {
+		return append([]PieceType{}, p.b.SecondHand...)
+	}
+	return append([]PieceType{}, p.b.FirstHand...)
+}
+
+// 合法手
+func (p Position) LegalMoves() []Move {
+	return p.b.GetAllLegalMoves()
+}
+
+// 指し手を指した後の局面（合法手であること。元の局面は変わらない）
+func (p Position) Play(move Move) Position {
+	next := p.b.Clone()
+	next.ApplyLegal(move)
+	return Position{next}
+}
+
+// 勝敗判定
+func (p Position) IsGameOver() (bool, Player) {
+	return p.b.IsGameOver()
+}
+
+// 評価値
+func (p Position) Evaluate() int {
+	return p.b.Evaluate()
+}
+
+// 指定した深さで探索した最善手
+func (p Position) BestMove(depth int) *Move {
+	return p.BestMoveContext(context.Background(), depth)
+}
+
+// 指定した深さで探索した最善手（ctxがキャンセルされたら途中の結果を返す）
+func (p Position) BestMoveContext(ctx context.Context, depth int) *Move {
+	_, move := p.b.Minimax(ctx, depth, -999999, 999999, p.b.CurrentTurn == First)
+	return move
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+//go:embed problems.txt
+var problemsText string
+
+// 練習問題の解析の探索深度
+const problemDepth = 4
+
+// 練習問題
+type Problem struct {
+	Title string // 題名（表示するときに訳す）
+	Mate  int    // 詰将棋なら手数（0ならAIの読みで最善手を判定する問題）
+	Board *Board // 出題局面
+}
+
+// 組み込みの練習問題を読み込み
+func builtinProblems() []Problem {
+	problems, err := parseProblems(problemsText)
+	if err != nil {
+		panic(err)
+	}
+	return problems
+}
+
+// 練習問題の一覧を解析（1行に「種類 | 局面（SFEN） | 題名」）
+func parseProblems(text string) ([]Problem, error) {
+	var problems []Problem
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Split(line, "|")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf(tr("%d行目: 形式が不正です"), i+1)
+		}
+		kind := strings.TrimSpace(fields[0])
+		b, err := ParseSFEN(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf(tr("%d行目: %v"), i+1, err)
+		}
+		p := Problem{Title: strings.TrimSpace(fields[2]), Board: b}
+		if n, ok := strings.CutPrefix(kind, "mate"); ok {
+			p.Mate, err = strconv.Atoi(n)
+			if err != nil || p.Mate%2 == 0 {
+				return nil, fmt.Errorf(tr("%d行目: 手数が不正です: %s"), i+1, kind)
+			}
+		} else if kind != "best" {
+			return nil, fmt.Errorf(tr("%d行目: 種類が不正です: %s"), i+1, kind)
+		}
+		problems = append(problems, p)
+	}
+	return problems, nil
+}
+
+// 問題の指示
+func (p Problem) Task() string {
+	if p.Mate > 0 {
+		return fmt.Sprintf(tr("%d手詰です。詰ませてください"), p.Mate)
+	}
+	return tr("最善手を探してください")
+}
+
+// 正解の手（詰将棋なら詰ませる手の1つ、そうでなければAIの最善手）
+func (p Problem) Answer() *Move {
+	if p.Mate > 0 {
+		return p.Board.MateIn(p.Mate)
+	}
+	_, move := p.Board.Minimax(context.Background(), problemDepth, -999999, 999999, p.Board.CurrentTurn == First)
+	return move
+}
+
+// problems サブコマンド: 組み込みの練習問題を解く
+func runProblems(args []string) int {
+	problems := builtinProblems()
+	if len(args) == 0 {
+		fmt.Println(tr("=== 練習問題 ==="))
+		for i, p := range problems {
+			fmt.Printf("%2d: %s\n", i+1, tr(p.Title))
+		}
+		fmt.Println("\n" + tr("mini-syogi problems <番号> で問題を解きます（all ですべて順に解きます）"))
+		return 0
+	}
+
+	selected := problems
+	if args[0] != "all" {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > len(problems) {
+			fmt.Fprintln(os.Stderr, tr("問題の番号が不正です:"), args[0])
+			return exitUsage
+		}
+		selected = problems[n-1 : n]
+	}
+
+	in := newLineReader(os.Stdin)
+	for _, p := range selected {
+		if r := solveProblem(p, in, os.Stdout); r.quit {
+			break
+		}
+	}
+	return 0
+}
+
+// 1問を解いた結果
+type problemResult struct {
+	solved bool // 自力で正解した
+	misses int  // 不正解だった回数
+	quit   bool // 入力が終わったか quit で中断した
+}
+
+// 1問を対話的に解く
+func solveProblem(p Problem, in *lineReader, out io.Writer) problemResult {
+	var result problemResult
+	fmt.Fprintf(out, "\n--- %s ---\n", tr(p.Title))
+	board := p.Board.Clone()
+	remaining := p.Mate
+	for {
+		board.Display(out)
+		fmt.Fprintf(out, "\n"+tr("%s（skip で答えを表示）: "), p.Task())
+		move, input, ok := promptMove(board, in, out)
+		switch {
+		case !ok || input == "quit":
+			result.quit = true
+			return result
+		case input == "skip":
+			if answer := (Problem{p.Title, remaining, board}).Answer(); answer != nil {
+				fmt.Fprintf(out, tr("正解の例: %s")+"\n", moveText(answer))
+			}
+			return result
+		case move == nil:
+			continue
+		}
+
+		if p.Mate == 0 {
+			a := analyzeMove(board, *move, problemDepth)
+			if a.loss(board.CurrentTurn) >= dubiousLoss {
+				fmt.Fprintln(out, tr("不正解です。もう一度どうぞ"))
+				result.misses++
+				continue
+			}
+			fmt.Fprintln(out, tr("正解です！"))
+			result.solved = true
+			return result
+		}
+
+		if !board.MatesWith(*move, remaining) {
+			fmt.Fprintln(out, tr("不正解です。もう一度どうぞ"))
+			result.misses++
+			continue
+		}
+		board.ApplyLegal(*move)
+		if !board.hasLegalMove(true) {
+			board.Display(out)
+			fmt.Fprintln(out, "\n"+tr("詰みました。正解です！"))
+			result.solved = true
+			return result
+		}
+		remaining -= 2
+		reply := board.longestDefense(remaining)
+		board.ApplyLegal(reply)
+		fmt.Fprintf(out, tr("玉方: %s")+"\n", moveText(&reply))
+	}
+}
+
+// 詰みまでの手数が最も長くなる応手（王手をかけられた側の手番で、limit手以内に詰むこと）
+func (b *Board) longestDefense(limit int) Move {
+	var best Move
+	longest := -1
+	b.ForEachLegalMove(func(m Move) bool {
+		next := b.Clone()
+		next.ApplyLegal(m)
+		if n := next.MateLength(limit); n > longest {
+			best, longest = m, n
+		}
+		return true
+	})
+	return best
+}
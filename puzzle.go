@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 問題の生成で盤上か持ち駒に置く駒（玉以外の全駒）
+var puzzlePieces = []PieceType{Gold, Gold, Silver, Silver, Bishop, Bishop, Rook, Rook, Pawn, Pawn}
+
+// ランダムな局面（攻め方の先手番。玉は双方の自陣寄りに置く）
+func randomPosition(rng *rand.Rand) *Board {
+	n := Minishogi{}.Size()
+	b := newEmptyBoard(n)
+	b.Cells[rng.Intn(2)][rng.Intn(n)] = Piece{King, Second}
+	for {
+		r, c := n-2+rng.Intn(2), rng.Intn(n)
+		if b.Cells[r][c].Owner == None {
+			b.Cells[r][c] = Piece{King, First}
+			break
+		}
+	}
+
+	pool := append([]PieceType{}, puzzlePieces...)
+	rng.Shuffle(len(pool), func(i, j int) { pool[i], pool[j] = pool[j], pool[i] })
+	for _, pType := range pool[:3+rng.Intn(4)] {
+		if rng.Intn(3) == 0 {
+			b.FirstHand = append(b.FirstHand, pType)
+			continue
+		}
+		for {
+			r, c := rng.Intn(n), rng.Intn(n)
+			owner := First
+			if rng.Intn(2) == 0 {
+				owner = Second
+			}
+			t := pType
+			if t != Gold && rng.Intn(4) == 0 {
+				t = promotedType(t)
+			}
+			if b.Cells[r][c].Owner != None ||
+				(t == Pawn && (b.rules().MustPromote(Piece{t, owner}, r) || b.hasPawnInColumn(c, owner))) {
+				continue
+			}
+			b.Cells[r][c] = Piece{t, owner}
+			break
+		}
+	}
+	return b
+}
+
+// 種から詰将棋を生成（同じ種なら同じ問題になる）
+// plies手詰で、それより短い手数では詰まず、詰ませる初手が1つだけ（余詰めがない）局面を選ぶ。
+func GeneratePuzzle(seed int64, plies int) Problem {
+	rng := rand.New(rand.NewSource(seed))
+	for {
+		b := randomPosition(rng)
+		if b.InCheck() || b.kingThreatened(Second) || !b.hasLegalMove(true) {
+			continue
+		}
+		if plies > 1 && b.IsMateIn(plies-2) {
+			continue
+		}
+		if len(b.MatingMoves(plies)) == 1 {
+			return Problem{fmt.Sprintf(tr("%d手詰"), plies), plies, b}
+		}
+	}
+}
+
+// 日付の問題（奇数日は3手詰、偶数日は1手詰）
+func dailyPuzzle(date time.Time) Problem {
+	y, m, d := date.Date()
+	plies := 1
+	if d%2 == 1 {
+		plies = 3
+	}
+	return GeneratePuzzle(int64(y*10000+int(m)*100+d), plies)
+}
+
+// 今日の問題の記録
+type puzzleStats struct {
+	LastDate string `json:"last_date"` // 最後に解いた日（YYYY-MM-DD）
+	Solved   bool   `json:"solved"`    // 最後に解いた日に正解したか
+	Misses   int    `json:"misses"`    // 最後に解いた日の不正解の回数
+	Streak   int    `json:"streak"`    // 連続正解日数
+	Best     int    `json:"best"`      // 最長の連続正解日数
+}
+
+// 記録ファイルの場所
+func puzzleStatsPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mini-syogi", "puzzle.json"), nil
+}
+
+// 記録を読み込み（まだなければ空の記録）
+func loadPuzzleStats(path string) (puzzleStats, error) {
+	var s puzzleStats
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	return s, json.Unmarshal(data, &s)
+}
+
+// 記録を保存
+func savePuzzleStats(path string, s puzzleStats) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// 解いた結果を記録に反映（前日も正解していれば連続日数を伸ばす）
+func (s *puzzleStats) record(date string, r problemResult) {
+	if t, err := time.Parse("2006-01-02", date); err == nil {
+		yesterday := t.AddDate(0, 0, -1).Format("2006-01-02")
+		if !(s.LastDate == yesterday && s.Solved) {
+			s.Streak = 0
+		}
+	}
+	s.LastDate, s.Solved, s.Misses = date, r.solved, r.misses
+	if r.solved {
+		s.Streak++
+	} else {
+		s.Streak = 0
+	}
+	if s.Streak > s.Best {
+		s.Best = s.Streak
+	}
+}
+
+// 共有用の結果の1行
+func (s puzzleStats) shareLine(title string) string {
+	result := tr("不正解")
+	if s.Solved {
+		result = fmt.Sprintf(tr("正解（不正解%d回）"), s.Misses)
+		if s.Misses == 0 {
+			result = tr("一発正解")
+		}
+	}
+	return fmt.Sprintf(tr("ミニ将棋 今日の詰将棋 %s %s %s 連続%d日"), s.LastDate, title, result, s.Streak)
+}
+
+// puzzle サブコマンド: 日付から決まる今日の詰将棋を解く
+func runPuzzle(args []string) int {
+	return runPuzzleInput(args, os.Stdin)
+}
+
+// 今日の詰将棋を、inから解答を読んで解く（開始メニューからも使う）
+func runPuzzleInput(args []string, in io.Reader) int {
+	fs := flag.NewFlagSet("puzzle", flag.ContinueOnError)
+	dateFlag := fs.String("date", "", "問題の日付（YYYY-MM-DD。省略すると今日）")
+	share := fs.Bool("share", false, "解かずに、今日の結果を共有用の1行で表示する")
+	setUsage(fs, "使い方: mini-syogi puzzle [-date YYYY-MM-DD] [-share]")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	date := time.Now()
+	if *dateFlag != "" {
+		t, err := time.Parse("2006-01-02", *dateFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("日付が不正です:"), *dateFlag)
+			return exitUsage
+		}
+		date = t
+	}
+	day := date.Format("2006-01-02")
+	p := dailyPuzzle(date)
+
+	path, err := puzzleStatsPath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("記録の場所がわかりません:"), err)
+		return exitError
+	}
+	stats, err := loadPuzzleStats(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("記録を読み込めません:"), err)
+		return exitError
+	}
+
+	if *share {
+		if stats.LastDate != day {
+			fmt.Fprintln(os.Stderr, tr("この日の問題はまだ解いていません"))
+			return exitError
+		}
+		fmt.Println(stats.shareLine(p.Title))
+		return 0
+	}
+	// 連続日数が崩れないように、最後に解いた日より後の問題だけを記録する
+	counted := day > stats.LastDate
+	if !counted {
+		fmt.Println(tr("この日までの問題は解答済みです（記録は更新しません）"))
+	}
+
+	fmt.Printf(tr("=== 今日の詰将棋（%s）===")+"\n", day)
+	r := solveProblem(p, newLineReader(in), os.Stdout)
+	if r.quit || !counted {
+		return 0
+	}
+	stats.record(day, r)
+	if err := savePuzzleStats(path, stats); err != nil {
+		fmt.Fprintln(os.Stderr, tr("記録を保存できません:"), err)
+		return exitError
+	}
+	fmt.Printf("\n"+tr("連続正解 %d日（最長 %d日）")+"\n", stats.Streak, stats.Best)
+	fmt.Println(stats.shareLine(p.Title))
+	return 0
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// 連続対局の通算成績（先後を入れ替えても対局者ごとに数える）
+type sessionScore struct {
+	names []string       // 対局者名（最初の対局の先手・後手の順）
+	wins  map[string]int // 対局者ごとの勝ち数
+	draws int
+	games int
+}
+
+// 対局結果を通算成績に加える
+func (s *sessionScore) add(r Result, first, second string) {
+	if s.wins == nil {
+		s.names = []string{first, second}
+		s.wins = make(map[string]int)
+	}
+	s.games++
+	switch r.Winner() {
+	case First:
+		s.wins[first]++
+	case Second:
+		s.wins[second]++
+	default:
+		if r.Decided() {
+			s.draws++
+		}
+	}
+}
+
+func (s sessionScore) String() string {
+	text := fmt.Sprintf(tr("通算成績: %s %d - %d %s"),
+		s.names[0], s.wins[s.names[0]], s.wins[s.names[1]], s.names[1])
+	if s.draws > 0 {
+		text += fmt.Sprintf(tr("（引き分け %d）"), s.draws)
+	}
+	return text
+}
+
+// 連続対局の最終成績を表示
+func (g *Game) printSessionSummary() {
+	s := g.session
+	fmt.Fprintf(g.out, "\n"+tr("=== 最終成績（%d局）===")+"\n", s.games)
+	for i, name := range s.names {
+		other := s.names[1-i]
+		fmt.Fprintf(g.out, tr("%s: %d勝 %d敗 %d分")+"\n", name, s.wins[name], s.wins[other], s.draws)
+	}
+}
+
+// 終局後に通算成績を表示して、もう一局指すか尋ねる（指すなら次の対局を準備してtrue）
+// 人間同士とAI同士では先後を交互に入れ替え、人間とAIの対局では入れ替えるかを選べる。
+func (g *Game) AskRematch() bool {
+	if g.stopped() {
+		return false
+	}
+	g.session.add(g.Result, g.Record.FirstName, g.Record.SecondName)
+	fmt.Fprintln(g.chat(), "\n"+g.session.String())
+	if g.AIPlayer == None {
+		fmt.Fprint(g.chat(), tr("先後を入れ替えてもう一局指しますか？ (y/n): "))
+	} else {
+		fmt.Fprint(g.chat(), tr("もう一局指しますか？ (y: 同じ手番で, s: 先後を入れ替えて, n: 終了): "))
+	}
+	g.jsonPrompt("rematch")
+	switch strings.TrimSpace(g.readLine()) {
+	case "y":
+		if g.AIPlayer == None {
+			g.swapColors()
+		}
+	case "s":
+		g.swapColors()
+	default:
+		if g.session.games > 1 {
+			g.printSessionSummary()
+		}
+		return false
+	}
+	g.rematch()
+	return true
+}
+
+// 先後を入れ替える
+func (g *Game) swapColors() {
+	g.players[First], g.players[Second] = g.players[Second], g.players[First]
+	g.engines[First], g.engines[Second] = g.engines[Second], g.engines[First]
+	g.clockLimits[First], g.clockLimits[Second] = g.clockLimits[Second], g.clockLimits[First]
+	if g.AIPlayer != None {
+		g.AIPlayer = g.AIPlayer.Opponent()
+	}
+	if g.bot != nil {
+		g.botSide = g.botSide.Opponent()
+	}
+}
+
+// 同じ開始局面・同じ設定で次の対局を準備
+func (g *Game) rematch() {
+	g.games++
+	g.SetPosition(g.Record.Initial)
+	g.setNames()
+	g.Result = Result{}
+	g.hopeless = [3]int{}
+	if g.Clock != nil {
+		g.useClocks(g.clockLimits)
+	}
+	if g.SaveFile != "" {
+		if g.saveBase == "" {
+			g.saveBase = g.SaveFile
+		}
+		g.SaveFile = numberedFile(g.saveBase, g.games+1)
+	}
+}
+
+// 連続対局の2局目以降の保存先（例: game.csa → game-2.csa）
+func numberedFile(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// 棋譜の再生（局面を1手ずつ進めたり戻したりして見る）
+type replayer struct {
+	record *Record
+	path   string // 棋譜ファイル（変化を加えて保存し直す）
+	depth  int    // 解析の探索深度
+	ply    int    // 表示している局面の手数（0なら開始局面）
+	prev   int    // 直前に表示していた局面の手数
+	diff   bool   // 直前に表示していた局面との違いを示す
+	line   []int  // 見ている手順（本譜から順にたどる変化の添字。nilなら本譜）
+	in     *lineReader
+	out    io.Writer
+}
+
+// 見ている手順の指し手（本譜か、分かれるまでの手と変化の手）
+func (rp *replayer) moves() []Move {
+	return rp.record.lineMoves(rp.line)
+}
+
+// 見ている手順をply手目まで進めた局面
+func (rp *replayer) position(ply int) *Board {
+	return rp.record.positionAfter(rp.moves(), ply)
+}
+
+// 表示している局面を盤面とともに表示
+func (rp *replayer) show() {
+	r := rp.record
+	moves := rp.moves()
+	var last *Move
+	if rp.ply == 0 {
+		fmt.Fprintf(rp.out, "\n"+tr("開始局面（全%d手）")+"\n", len(moves))
+	} else {
+		last = &moves[rp.ply-1]
+		fmt.Fprintf(rp.out, "\n"+tr("%d手目: %s%s")+"\n", rp.ply, playerMark(rp.position(rp.ply-1).CurrentTurn), moveText(last))
+	}
+	switch {
+	case len(rp.line) == 1:
+		fmt.Fprintf(rp.out, tr("（変化: 本譜の%d手目から分かれた手順。main で本譜に戻る）")+"\n", r.variation(rp.line).Ply+1)
+	case len(rp.line) > 1:
+		fmt.Fprintf(rp.out, tr("（変化の中の変化: %d手目から分かれた手順。up で一つ外の手順に、main で本譜に戻る）")+"\n", r.variation(rp.line).Ply+1)
+	}
+	b := rp.position(rp.ply)
+	if rp.diff {
+		before := rp.position(rp.prev)
+		b.displayCells(rp.out, func(row, col int) string {
+			s := currentTheme.cell(b.Cells[row][col])
+			if b.Cells[row][col] != before.Cells[row][col] {
+				return currentTheme.lastMoveCell(s)
+			}
+			return s
+		})
+		rp.printChanges()
+	} else {
+		b.DisplayMove(rp.out, last)
+	}
+	if rp.line == nil && rp.ply > 0 && rp.ply <= len(r.Notes) && r.Notes[rp.ply-1].Comment != "" {
+		fmt.Fprintln(rp.out, tr("コメント:"), r.Notes[rp.ply-1].Comment)
+	}
+	for i, n := range r.variationsAt(rp.line, rp.ply) {
+		v := rp.branches()[n]
+		fmt.Fprintf(rp.out, tr("変化%d: %s%s（%d手。var %d で見る）")+"\n", i+1, playerMark(b.CurrentTurn), moveText(&v.Moves[0]), len(v.Moves), i+1)
+	}
+	if rp.line == nil && rp.ply == len(r.Moves) && r.End != "" {
+		fmt.Fprintln(rp.out, tr("終局:"), tr(kifEnds[r.End]))
+	}
+}
+
+// 見ている手順から分かれる変化
+func (rp *replayer) branches() []Variation {
+	if v := rp.record.variation(rp.line); v != nil {
+		return v.Variations
+	}
+	return rp.record.Variations
+}
+
+// 直前に表示していた局面からの駒取りと駒打ちを一覧にする（戻ったときは戻した手の分）
+func (rp *replayer) printChanges() {
+	from, to := min(rp.prev, rp.ply), max(rp.prev, rp.ply)
+	if from == to {
+		return
+	}
+	span := fmt.Sprintf(tr("%d手目〜%d手目"), from+1, to)
+	if to-from == 1 {
+		span = fmt.Sprintf(tr("%d手目"), to)
+	}
+	if rp.ply < rp.prev {
+		fmt.Fprintf(rp.out, tr("%d手戻しました（%s）")+"\n", to-from, span)
+	} else {
+		fmt.Fprintf(rp.out, tr("%d手進めました（%s）")+"\n", to-from, span)
+	}
+	b := rp.position(from)
+	found := false
+	for i := from; i < to; i++ {
+		m := rp.moves()[i]
+		mark := playerMark(b.CurrentTurn)
+		if m.IsDrop {
+			fmt.Fprintf(rp.out, tr("  %d手目 %s%s")+"\n", i+1, mark, moveText(&m))
+			found = true
+		} else if captured := b.Cells[m.ToRow][m.ToCol]; captured.Owner != None {
+			fmt.Fprintf(rp.out, tr("  %d手目 %s%d%sの%sが%d%sの%sを取る")+"\n", i+1, mark,
+				m.FromCol+1, rankNames[m.FromRow], currentTheme.pieceName(b.Cells[m.FromRow][m.FromCol].Type),
+				m.ToCol+1, rankNames[m.ToRow], currentTheme.pieceName(captured.Type))
+			found = true
+		}
+		b.ApplyLegal(m)
+	}
+	if !found {
+		fmt.Fprintln(rp.out, tr("  駒取り・駒打ちはありません"))
+	}
+}
+
+// analyze コマンド: 表示している局面の最善手と評価値、棋譜の次の手との差を表示
+func (rp *replayer) analyze() {
+	b := rp.position(rp.ply)
+	if !b.hasLegalMove(true) {
+		fmt.Fprintln(rp.out, tr("指せる手がありません"))
+		return
+	}
+	result := b.Analyze(context.Background(), SearchOptions{Depth: rp.depth, Ply: rp.ply})
+	fmt.Fprintf(rp.out, tr("最善手: %s%s（評価値 %d、深さ%d）")+"\n", playerMark(b.CurrentTurn), moveText(result.Move), result.Score, rp.depth)
+	moves := rp.moves()
+	if rp.ply == len(moves) {
+		return
+	}
+	next := moves[rp.ply]
+	a := analyzeMove(b, next, rp.depth)
+	text := fmt.Sprintf(tr("棋譜の手: %s%s（評価値 %d"), playerMark(b.CurrentTurn), moveText(&next), a.Played)
+	if loss := a.loss(b.CurrentTurn); loss > 0 {
+		text += fmt.Sprintf(tr("、最善手との差 %d"), loss)
+		if mark := lossMark(loss); mark != "" {
+			text += "、" + mark
+		}
+	}
+	fmt.Fprintln(rp.out, text+"）")
+}
+
+// note コマンド: 表示している局面に至った本譜の手にコメントを付け、棋譜ファイルに保存する
+func (rp *replayer) note(text string) {
+	if rp.line != nil || rp.ply == 0 {
+		fmt.Fprintln(rp.out, tr("コメントは本譜の指し手に付けます（開始局面と変化には付けられません）"))
+		return
+	}
+	rp.record.CommentAt(rp.ply-1, text)
+	if err := saveRecord(rp.path, rp.record); err != nil {
+		fmt.Fprintln(rp.out, tr("棋譜を保存できません:"), err)
+		return
+	}
+	fmt.Fprintf(rp.out, tr("%d手目にコメントを付けました（%s に保存しました）")+"\n", rp.ply, rp.path)
+}
+
+// play コマンド: 表示している局面から対局する（対局の手順は元の棋譜に変化として加え、棋譜ファイルに保存し直す）
+// modeは人間とAIの組み合わせ（ModeNoneなら手番の側を人間が持ってAIと指す）。
+func (rp *replayer) play(mode Mode) {
+	branch := rp.ply
+	if rp.line != nil {
+		branch = min(rp.ply, rp.record.variation(rp.line[:1]).Ply)
+	}
+	r := rp.record.Branch(branch)
+	for _, m := range rp.moves()[branch:rp.ply] {
+		r.Add(m, 0)
+	}
+	g := NewGame(&lineReaderInput{lr: rp.in}, rp.out, systemTime{})
+	g.Resume(r)
+	if mode == ModeNone {
+		mode = ModeHumanFirst
+		if g.Board.CurrentTurn == Second {
+			mode = ModeHumanSecond
+		}
+	}
+	g.SetMode(mode)
+	g.SaveFile = rp.path
+	g.saveAs = func(path string, played *Record) error {
+		rp.record.AddLine(played.Moves)
+		return saveRecord(path, rp.record)
+	}
+	fmt.Fprintf(rp.out, "\n"+tr("%d手目から対局します（対局の手順は変化として %s に保存します）")+"\n", rp.ply, g.SaveFile)
+	handleInterrupt(g)
+	g.Run()
+}
+
+// 行ごとに読んだ入力を改めてio.Readerにする（再生の入力を対局に引き継ぐ）
+type lineReaderInput struct {
+	lr  *lineReader
+	buf []byte
+}
+
+func (r *lineReaderInput) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		line, ok := r.lr.read()
+		if !ok {
+			return 0, io.EOF
+		}
+		r.buf = []byte(line + "\n")
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// 入力を読みながら再生する（q か入力の終わりで終える。play で対局を始めたら、対局が終わると終える）
+func (rp *replayer) run() {
+	rp.show()
+	for {
+		last := len(rp.moves())
+		fmt.Fprintf(rp.out, tr("[%d/%d] n: 次, p: 前, 数字: その手数へ, s: 最初, e: 最後, var N・up・main: 変化・外の手順・本譜へ, diff: 違いの表示, analyze: 解析, note 文: コメント, play: ここから対局, q: 終了 > "), rp.ply, last)
+		input, ok := rp.in.read()
+		if !ok || input == "q" {
+			fmt.Fprintln(rp.out)
+			return
+		}
+		if arg, found := strings.CutPrefix(input, "play"); found {
+			mode, err := parseMode(strings.TrimSpace(arg))
+			if err != nil || mode > ModeSelfPlay {
+				fmt.Fprintln(rp.out, tr("play のあとには sente・gote・hotseat・selfplay のどれかを指定します"))
+				continue
+			}
+			rp.play(mode)
+			return
+		}
+		if text, found := strings.CutPrefix(input, "note "); found && strings.TrimSpace(text) != "" {
+			rp.note(strings.TrimSpace(text))
+			continue
+		}
+		if arg, found := strings.CutPrefix(input, "var "); found {
+			vs := rp.record.variationsAt(rp.line, rp.ply)
+			n, err := strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || n < 1 || n > len(vs) {
+				fmt.Fprintln(rp.out, tr("この局面から分かれる変化はありません（表示された変化の番号を指定します）"))
+				continue
+			}
+			rp.line = append(slices.Clip(rp.line), vs[n-1])
+			rp.prev, rp.ply = rp.ply, rp.ply+1
+			rp.show()
+			continue
+		}
+		ply := rp.ply
+		switch input {
+		case "analyze":
+			rp.analyze()
+			continue
+		case "main", "up":
+			if rp.line == nil {
+				fmt.Fprintln(rp.out, tr("本譜を見ています"))
+				continue
+			}
+			if input == "main" {
+				rp.line = rp.line[:1]
+			}
+			ply = min(ply, rp.record.variation(rp.line).Ply)
+			rp.line = rp.line[:len(rp.line)-1]
+			if len(rp.line) == 0 {
+				rp.line = nil
+			}
+		case "", "n":
+			if ply == last {
+				fmt.Fprintln(rp.out, tr("最後の局面です"))
+				continue
+			}
+			ply++
+		case "p":
+			if ply == 0 {
+				fmt.Fprintln(rp.out, tr("開始局面です"))
+				continue
+			}
+			ply--
+		case "s":
+			ply = 0
+		case "e":
+			ply = last
+		case "diff":
+			rp.diff = !rp.diff
+			if rp.diff {
+				fmt.Fprintln(rp.out, tr("違いの表示: オン（前に見ていた局面から変わったマスに印を付けます）"))
+			} else {
+				fmt.Fprintln(rp.out, tr("違いの表示: オフ"))
+			}
+			continue
+		default:
+			n, err := strconv.Atoi(input)
+			if err != nil || n < 0 || n > last {
+				fmt.Fprintf(rp.out, tr("0〜%dの手数か、n・p・s・e・diff・var・up・main・analyze・note・play・q を入力してください")+"\n", last)
+				continue
+			}
+			ply = n
+		}
+		rp.prev, rp.ply = rp.ply, ply
+		rp.show()
+	}
+}
+
+// replay サブコマンド: 棋譜ファイルを1手ずつ再生する
+func runReplay(args []string) int {
+	return runReplayInput(args, os.Stdin)
+}
+
+// 棋譜を、inから操作を読んで再生する（開始メニューからも使う）
+func runReplayInput(args []string, in io.Reader) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	diff := fs.Bool("diff", false, "前に見ていた局面との違いを示す（再生中に diff で切り替えられる）")
+	depth := fs.Int("depth", trainingDepth, "analyze で解析するときの探索深度")
+	setUsage(fs, "使い方: mini-syogi replay [-diff] [-depth N] <棋譜ファイル>")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 || strings.TrimSpace(fs.Arg(0)) == "" {
+		fs.Usage()
+		return exitUsage
+	}
+	r, err := loadRecord(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("棋譜を読み込めません:"), err)
+		return exitError
+	}
+	fmt.Printf(tr("先手: %s / 後手: %s")+"\n", r.FirstName, r.SecondName)
+	if *depth < 1 {
+		fmt.Fprintln(os.Stderr, tr("探索深度は1以上です:"), *depth)
+		return exitUsage
+	}
+	rp := &replayer{record: r, path: fs.Arg(0), depth: *depth, diff: *diff, in: newLineReader(in), out: os.Stdout}
+	rp.run()
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import "fmt"
+
+// 勝敗
+type Outcome int
+
+const (
+	Undecided Outcome = iota
+	SenteWin          // 先手の勝ち
+	GoteWin           // 後手の勝ち
+	Draw              // 引き分け
+)
+
+// 終局理由
+type Reason int
+
+const (
+	ReasonNone        Reason = iota
+	ReasonMate               // 詰み
+	ReasonResign             // 投了
+	ReasonTimeout            // 時間切れ
+	ReasonRepetition         // 千日手
+	ReasonIllegalMove        // 反則
+	ReasonTry                // トライ
+)
+
+// 対局結果
+type Result struct {
+	Outcome Outcome
+	Reason  Reason
+}
+
+// 指定したプレイヤーの勝ち
+func winResult(winner Player, reason Reason) Result {
+	if winner == First {
+		return Result{SenteWin, reason}
+	}
+	return Result{GoteWin, reason}
+}
+
+// 勝者（引き分け・未決着ならNone）
+func (r Result) Winner() Player {
+	switch r.Outcome {
+	case SenteWin:
+		return First
+	case GoteWin:
+		return Second
+	}
+	return None
+}
+
+// 終局しているか
+func (r Result) Decided() bool {
+	return r.Outcome != Undecided
+}
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonMate:
+		return tr("詰み")
+	case ReasonResign:
+		return tr("投了")
+	case ReasonTimeout:
+		return tr("時間切れ")
+	case ReasonRepetition:
+		return tr("千日手")
+	case ReasonIllegalMove:
+		return tr("反則")
+	case ReasonTry:
+		return tr("トライ")
+	}
+	return ""
+}
+
+func (r Result) String() string {
+	switch r.Outcome {
+	case SenteWin:
+		return fmt.Sprintf(tr("先手の勝ちです！（%s）"), r.Reason)
+	case GoteWin:
+		return fmt.Sprintf(tr("後手の勝ちです！（%s）"), r.Reason)
+	case Draw:
+		return fmt.Sprintf(tr("%sで引き分けです"), r.Reason)
+	}
+	return tr("対局中")
+}
+
+// CSA形式の終局の特殊手
+func (r Result) csaEnd() string {
+	switch r.Reason {
+	case ReasonMate:
+		return "%TSUMI"
+	case ReasonResign:
+		return "%TORYO"
+	case ReasonTimeout:
+		return "%TIME_UP"
+	case ReasonRepetition:
+		return "%SENNICHITE"
+	case ReasonIllegalMove:
+		return "%ILLEGAL_MOVE"
+	case ReasonTry:
+		return "%TRY"
+	}
+	return ""
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 対局のルール（盤の大きさ、初期配置、駒の動き、成り、持ち駒、勝敗）
+// 変則ルールはこのインターフェースを実装して Board.Rules に設定する。
+type Rules interface {
+	// ルールの名前（棋譜の 'VARIANT 行に書く。標準ルールなら空）
+	Name() string
+	// 盤の一辺のマス数（座標を1桁の数字で書くので、1〜maxBoardSize）
+	Size() int
+	// 初期局面
+	Setup() *Board
+	// 駒が1マスだけ動ける方向（行, 列の差）
+	Steps(p Piece) [][2]int
+	// 駒が他の駒にぶつかるまで何マスでも動ける方向
+	Slides(p Piece) [][2]int
+	// 駒がtoRowへ動くときに成れるか
+	CanPromote(p Piece, toRow int) bool
+	// 成らないと行き所のない駒になるか
+	MustPromote(p Piece, toRow int) bool
+	// 取った駒を持ち駒にして打てるか
+	Drops() bool
+	// 持ち駒を打てない理由（打てるならnil。空きマスで持ち駒にあることは確認済み）
+	DropError(b *Board, pType PieceType, row, col int) error
+	// 勝敗（決着していればtrueと勝者）
+	Winner(b *Board) (bool, Player)
+	// 詰み以外の勝ちの条件（トライなど。なければnil。Winnerより先に調べる）
+	WinConditions() []WinCondition
+}
+
+// 詰み以外で勝ちになる条件
+// 変則ルールは Rules.WinConditions で返すと、対局の勝敗判定とAIの探索の両方で使われる。
+type WinCondition interface {
+	// 手を指した直後の局面で条件を満たしていればtrueと勝者
+	Check(b *Board) (bool, Player)
+	// 決着の理由（棋譜の終局やJSONの出力に使う）
+	Reason() Reason
+	// 決着したときの表示
+	Announce(winner Player) string
+}
+
+// 5五将棋
+type Minishogi struct {
+	NoDrops   bool // 持ち駒なし（取った駒は持ち駒にならず盤から除かれる）
+	ZoneRanks int  // 敵陣の段数（0なら標準の1段）
+	Try       bool // トライルール（玉が相手の一段目に入り、取られなければ勝ち）
+}
+
+var (
+	kingSteps     = [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, -1}, {1, 0}, {1, 1}}
+	orthogonal    = [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	diagonal      = [][2]int{{-1, -1}, {-1, 1}, {1, -1}, {1, 1}}
+	horseSteps    = [][2]int{{-1, 0}, {0, -1}, {0, 1}, {1, 0}}
+	minishogiMove [3][PromotedPawn + 1]struct{ steps, slides [][2]int } // 手番ごと・駒ごとの動き
+)
+
+func init() {
+	for _, owner := range []Player{First, Second} {
+		m := &minishogiMove[owner]
+		m[King].steps = kingSteps
+		for _, t := range []PieceType{Gold, PromotedSilver, PromotedPawn} {
+			m[t].steps = goldSteps(owner)
+		}
+		m[Silver].steps = silverSteps(owner)
+		m[Bishop].slides = diagonal
+		m[PromotedBishop].slides, m[PromotedBishop].steps = diagonal, horseSteps
+		m[Rook].slides = orthogonal
+		m[PromotedRook].slides, m[PromotedRook].steps = orthogonal, diagonal
+		m[Pawn].steps = [][2]int{{forward(owner), 0}}
+	}
+}
+
+// 前方向の行の差
+func forward(player Player) int {
+	if player == First {
+		return -1
+	}
+	return 1
+}
+
+func goldSteps(player Player) [][2]int {
+	if player == First {
+		return [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {0, -1}, {0, 1}, {1, 0}}
+	}
+	return [][2]int{{1, -1}, {1, 0}, {1, 1}, {0, -1}, {0, 1}, {-1, 0}}
+}
+
+func silverSteps(player Player) [][2]int {
+	if player == First {
+		return [][2]int{{-1, -1}, {-1, 0}, {-1, 1}, {1, -1}, {1, 1}}
+	}
+	return [][2]int{{1, -1}, {1, 0}, {1, 1}, {-1, -1}, {-1, 1}}
+}
+
+func (m Minishogi) Name() string {
+	var names []string
+	if m.NoDrops {
+		names = append(names, "nodrops")
+	}
+	if m.zoneRanks() != 1 {
+		names = append(names, fmt.Sprintf("zone=%d", m.zoneRanks()))
+	}
+	if m.Try {
+		names = append(names, "try")
+	}
+	return strings.Join(names, " ")
+}
+
+func (m Minishogi) Size() int {
+	return 5
+}
+
+// 5五将棋の標準配置
+func (m Minishogi) Setup() *Board {
+	b := newEmptyBoard(m.Size())
+	if m != (Minishogi{}) {
+		b.Rules = m
+	}
+
+	// 後手（上側）
+	b.Cells[0][0] = Piece{Rook, Second}
+	b.Cells[0][1] = Piece{Bishop, Second}
+	b.Cells[0][2] = Piece{Silver, Second}
+	b.Cells[0][3] = Piece{Gold, Second}
+	b.Cells[0][4] = Piece{King, Second}
+	b.Cells[1][4] = Piece{Pawn, Second}
+
+	// 先手（下側）
+	b.Cells[4][4] = Piece{Rook, First}
+	b.Cells[4][3] = Piece{Bishop, First}
+	b.Cells[4][2] = Piece{Silver, First}
+	b.Cells[4][1] = Piece{Gold, First}
+	b.Cells[4][0] = Piece{King, First}
+	b.Cells[3][0] = Piece{Pawn, First}
+
+	return b
+}
+
+func (m Minishogi) Steps(p Piece) [][2]int {
+	return minishogiMove[p.Owner][p.Type].steps
+}
+
+func (m Minishogi) Slides(p Piece) [][2]int {
+	return minishogiMove[p.Owner][p.Type].slides
+}
+
+// 敵陣の段数
+func (m Minishogi) zoneRanks() int {
+	if m.ZoneRanks <= 0 {
+		return 1
+	}
+	return m.ZoneRanks
+}
+
+// 銀・角・飛・歩が敵陣に入るときに成れる
+func (m Minishogi) CanPromote(p Piece, toRow int) bool {
+	switch p.Type {
+	case Silver, Bishop, Rook, Pawn:
+	default:
+		return false
+	}
+	if p.Owner == First {
+		return toRow < m.zoneRanks()
+	}
+	return toRow >= m.Size()-m.zoneRanks()
+}
+
+// 歩は最奥段で必ず成る
+func (m Minishogi) MustPromote(p Piece, toRow int) bool {
+	return p.Type == Pawn && m.isDeadRank(p.Owner, toRow)
+}
+
+func (m Minishogi) Drops() bool {
+	return !m.NoDrops
+}
+
+// 二歩と行き所のない歩は打てない
+func (m Minishogi) DropError(b *Board, pType PieceType, row, col int) error {
+	if pType != Pawn {
+		return nil
+	}
+	if b.hasPawnInColumn(col, b.CurrentTurn) {
+		return ErrNifu
+	}
+	if m.isDeadRank(b.CurrentTurn, row) {
+		return ErrDeadPiece
+	}
+	return nil
+}
+
+// 玉を取られるか、指せる手がなくなった側の負け
+func (m Minishogi) Winner(b *Board) (bool, Player) {
+	firstKing, secondKing := false, false
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			if b.Cells[r][c].Type == King {
+				if b.Cells[r][c].Owner == First {
+					firstKing = true
+				} else if b.Cells[r][c].Owner == Second {
+					secondKing = true
+				}
+			}
+		}
+	}
+
+	if !firstKing {
+		return true, Second
+	}
+	if !secondKing {
+		return true, First
+	}
+
+	// 詰み（指せる手がない）
+	if !b.hasLegalMove(true) {
+		return true, b.CurrentTurn.Opponent()
+	}
+
+	return false, None
+}
+
+func (m Minishogi) WinConditions() []WinCondition {
+	if m.Try {
+		return []WinCondition{tryRule{}}
+	}
+	return nil
+}
+
+// トライルール: 手を指した側の玉が相手の一段目にあり、相手の駒に利かれていなければ勝ち
+type tryRule struct{}
+
+func (tryRule) Check(b *Board) (bool, Player) {
+	mover := b.CurrentTurn.Opponent()
+	row := 0
+	if mover == Second {
+		row = b.Size() - 1
+	}
+	for c := 0; c < b.Size(); c++ {
+		if p := b.Cells[row][c]; p.Type == King && p.Owner == mover {
+			return !b.IsAttacked(row, c, b.CurrentTurn), mover
+		}
+	}
+	return false, None
+}
+
+func (tryRule) Reason() Reason {
+	return ReasonTry
+}
+
+func (tryRule) Announce(winner Player) string {
+	if winner == Second {
+		return tr("後手の玉が相手の一段目に入りました（トライ）")
+	}
+	return tr("先手の玉が相手の一段目に入りました（トライ）")
+}
+
+// 歩がそれ以上進めない段か
+func (m Minishogi) isDeadRank(player Player, row int) bool {
+	if player == First {
+		return row == 0
+	}
+	return row == m.Size()-1
+}
+
+// ルールの名前（例: "nodrops zone=2"）からルールを作る
+func ParseRules(name string) (Rules, error) {
+	var m Minishogi
+	for _, field := range strings.Fields(name) {
+		switch {
+		case field == "nodrops":
+			m.NoDrops = true
+		case field == "try":
+			m.Try = true
+		case strings.HasPrefix(field, "zone="):
+			n, err := strconv.Atoi(field[len("zone="):])
+			if err != nil || n < 1 || n > 2 {
+				return nil, fmt.Errorf(tr("敵陣の段数が不正です: %s"), field)
+			}
+			m.ZoneRanks = n
+		default:
+			return nil, fmt.Errorf(tr("未対応の変則ルールです: %s"), field)
+		}
+	}
+	return m, nil
+}
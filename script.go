@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// 台本の指し手を指し終えたことを表すキャンセルの理由
+var errEndOfScript = errors.New("台本の終わり")
+
+// 台本に指せない手があったことを表すキャンセルの理由
+var errBadScript = errors.New("台本の指し手が不正")
+
+// 台本の指し手で対局する（先手・後手とも台本の順に指し、入力は待たない）
+func (g *Game) UseScript(moves []string) {
+	g.Script = moves
+	g.AIPlayer, g.SelfPlay = None, false
+	g.modeSelected = true
+	g.players[First], g.players[Second] = tr("対局者1"), tr("対局者2")
+	g.setNames()
+}
+
+// 台本を読み込む（空白や改行で区切った指し手。"-" なら標準入力から）
+func parseScript(arg string, stdin io.Reader) ([]string, error) {
+	if arg != "-" {
+		return strings.Fields(arg), nil
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// 台本の次の手（台本が終わったか指せない手なら手番を打ち切ってnil）
+// 成るかを尋ねられないので、通信対局と同じく成る手は 4142+ のように書く。
+func (g *Game) scriptMove() *Move {
+	if len(g.Script) == 0 {
+		g.endTurn(errEndOfScript)
+		return nil
+	}
+	input := g.Script[0]
+	g.Script = g.Script[1:]
+	if input == "resign" || input == "投了" {
+		g.Result = winResult(g.Board.CurrentTurn.Opponent(), ReasonResign)
+		return nil
+	}
+	move, err := parseCorrespondenceMove(g.Board, input)
+	if err != nil {
+		g.printError(errorCode(err, "invalid_input"), fmt.Sprintf(tr("台本の %s は指せません（%s）"), input, err))
+		g.scriptFailed = true
+		g.endTurn(errBadScript)
+		return nil
+	}
+	return &move
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AIの既定の探索深度
+const defaultDepth = 3
+
+// 探索の設定
+type SearchOptions struct {
+	Depth     int           // 探索深度（Remainingを指定した場合は無視する）
+	Contempt  int           // 千日手を嫌う度合い（探索する側から見た千日手の評価値を -Contempt にする）
+	Remaining time.Duration // 探索する側の残り時間（0なら時間を気にせずDepthまで読む）
+	Ply       int           // 対局開始からの手数（時間配分の見積もりに使う）
+	Log       io.Writer     // 探索の記録の出力先（nilなら記録しない）
+	Nodes     int           // 探索する局面の数の上限（0なら制限しない。上限に達した深さの結果は使わない）
+	Eval      *EvalParams   // 評価パラメータ（nilなら現在の評価パラメータ）
+	Now       TimeSource    // 時刻の取得元（nilなら実際の時刻。時間配分と打ち切りに使う）
+	Threads   int           // 初手を分担して読むゴルーチンの数（1以下なら分担しない）
+
+	history map[string]int // 対局中に現れた局面（千日手の検出に使う。nilなら検出しない）
+}
+
+// 探索の結果
+type SearchResult struct {
+	Move  *Move // 最善手（指せる手がなければnil）
+	Score int   // 評価値（先手から見た値）
+	Depth int   // 読み切った深さ（読まずに指した場合は0）
+}
+
+// 探索の状態
+type searcher struct {
+	ctx   context.Context
+	opts  SearchOptions
+	root  Player         // 探索を開始した局面の手番
+	seen  map[string]int // 対局中と探索中の手順に現れた局面
+	nodes int            // 探索した局面の数
+	limit int            // この局面の数に達したら探索を打ち切る（0なら打ち切らない）
+	now   TimeSource     // 時刻の取得元
+
+	shared *atomic.Int64 // 初手を分担して読むときに、すべてのゴルーチンで数える局面の数（nilならnodesだけ）
+}
+
+// 設定に従って最善手を探索（ctxがキャンセルされたら途中の結果を返す）
+func (b *Board) Search(ctx context.Context, opts SearchOptions) *Move {
+	return b.Analyze(ctx, opts).Move
+}
+
+// 設定に従って探索し、最善手と評価値を求める
+func (b *Board) Analyze(ctx context.Context, opts SearchOptions) SearchResult {
+	s := &searcher{ctx: ctx, opts: opts, root: b.CurrentTurn, now: opts.Now}
+	if s.now == nil {
+		s.now = systemTime{}
+	}
+	if opts.history != nil {
+		s.seen = make(map[string]int, len(opts.history))
+		for key, n := range opts.history {
+			s.seen[key] = n
+		}
+	}
+
+	start := s.now.Now()
+	s.logf(tr("探索開始 sfen %s 残り時間=%v 局面数上限=%d 千日手補正=%d"), b.SFEN(s.opts.Ply+1), s.opts.Remaining, s.opts.Nodes, s.opts.Contempt)
+	result := s.search(b)
+	switch {
+	case ctx.Err() != nil:
+		s.logf(tr("中断 時間=%v"), s.now.Now().Sub(start))
+	case result.Move == nil:
+		s.logf(tr("決定 指し手なし 時間=%v"), s.now.Now().Sub(start))
+	default:
+		s.logf(tr("決定 %s 局面数=%d 時間=%v"), csaMove(b, *result.Move), s.nodes, s.now.Now().Sub(start))
+	}
+	return result
+}
+
+func (s *searcher) search(b *Board) SearchResult {
+	// 合法手が1つしかなければ読まずにすぐ指す
+	if move, ok := b.onlyMove(); ok {
+		s.logf("%s", tr("合法手が1つのため読まずに指す"))
+		next := b.Clone()
+		next.ApplyLegal(*move)
+		return SearchResult{move, s.evaluate(next), 0}
+	}
+	if s.opts.Remaining > 0 || s.opts.Nodes > 0 {
+		return s.searchIterative(b)
+	}
+	start := s.now.Now()
+	eval, move := s.searchRoot(b, s.opts.Depth)
+	s.logIteration(b, s.opts.Depth, eval, move, start)
+	return SearchResult{move, eval, s.opts.Depth}
+}
+
+// 探索の記録を1行書き出す
+func (s *searcher) logf(format string, args ...interface{}) {
+	if s.opts.Log == nil {
+		return
+	}
+	fmt.Fprintf(s.opts.Log, "%s %s\n", s.now.Now().Format("2006-01-02 15:04:05.000"), fmt.Sprintf(format, args...))
+}
+
+// 1回の探索（反復深化の1反復）の結果を記録
+func (s *searcher) logIteration(b *Board, depth, eval int, move *Move, start time.Time) {
+	if s.aborted() {
+		s.logf(tr("  深さ%d 打ち切り 時間=%v"), depth, s.now.Now().Sub(start))
+		return
+	}
+	best := tr("なし")
+	if move != nil {
+		best = csaMove(b, *move)
+	}
+	s.logf(tr("  深さ%d 評価値=%d 最善手=%s 時間=%v"), depth, eval, best, s.now.Now().Sub(start))
+}
+
+// 探索の設定の評価パラメータで評価する
+func (s *searcher) evaluate(b *Board) int {
+	if s.opts.Eval != nil {
+		return b.evaluateWith(s.opts.Eval)
+	}
+	return b.Evaluate()
+}
+
+// 探索を打ち切るか（キャンセルされたか、局面の数の上限に達した）
+func (s *searcher) aborted() bool {
+	nodes := s.nodes
+	if s.shared != nil {
+		nodes = int(s.shared.Load())
+	}
+	return s.ctx.Err() != nil || s.limit > 0 && nodes >= s.limit
+}
+
+// 開始局面をdepthまで読む（Threadsが2以上なら、初手をゴルーチンで分担する）
+// 分担するときは初手ごとに窓を狭めずに読むので、評価値と最善手は分担しない場合と同じになる。
+func (s *searcher) searchRoot(b *Board, depth int) (int, *Move) {
+	maximizing := b.CurrentTurn == First
+	var moves []Move
+	if s.opts.Threads > 1 {
+		moves = b.GetAllLegalMoves()
+	}
+	if len(moves) < 2 {
+		return s.minimax(b, depth, -999999, 999999, maximizing)
+	}
+
+	s.nodes++
+	var shared atomic.Int64
+	shared.Store(int64(s.nodes))
+	evals := make([]int, len(moves))
+	next := make(chan int)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range min(s.opts.Threads, len(moves)) {
+		// 千日手の検出に使う局面はゴルーチンごとに持つ
+		w := &searcher{ctx: s.ctx, opts: s.opts, root: s.root, limit: s.limit, now: s.now, shared: &shared}
+		if s.seen != nil {
+			w.seen = make(map[string]int, len(s.seen))
+			for key, n := range s.seen {
+				w.seen[key] = n
+			}
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				child := b.pooledClone()
+				child.ApplyLegal(moves[i])
+				evals[i] = w.childEval(child, depth, -999999, 999999, maximizing)
+				putBoard(child)
+			}
+			mu.Lock()
+			s.nodes += w.nodes
+			mu.Unlock()
+		}()
+	}
+	for i := range moves {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	best := 0
+	for i, eval := range evals {
+		if (maximizing && eval > evals[best]) || (!maximizing && eval < evals[best]) {
+			best = i
+		}
+	}
+	return evals[best], &moves[best]
+}
+
+// 合法手がちょうど1つのときはその手
+func (b *Board) onlyMove() (*Move, bool) {
+	var only *Move
+	count := 0
+	b.ForEachLegalMove(func(m Move) bool {
+		count++
+		only = &m
+		return count < 2
+	})
+	return only, count == 1
+}
+
+// 千日手の評価値（先手から見た値）
+// 探索する側が引き分けを嫌うほど、その側にとって低い値になる。
+func (s *searcher) drawScore() int {
+	if s.root == First {
+		return -s.opts.Contempt
+	}
+	return s.opts.Contempt
+}
+
+// ミニマックス法（アルファベータ枝刈り）
+// 探索中に同一局面が再び現れたら、それ以上読まずに千日手とみなす。
+func (s *searcher) minimax(b *Board, depth int, alpha, beta int, maximizing bool) (int, *Move) {
+	s.nodes++
+	if s.shared != nil {
+		s.shared.Add(1)
+	}
+	if depth == 0 {
+		return s.evaluate(b), nil
+	}
+	if s.aborted() {
+		return 0, nil
+	}
+
+	buf := getMoves()
+	defer putMoves(buf)
+	b.forEachPseudoLegalMove(func(m Move) bool {
+		*buf = append(*buf, m)
+		return true
+	})
+	moves := *buf
+	legal := 0
+
+	var bestMove *Move
+	bestEval := 999999
+	if maximizing {
+		bestEval = -999999
+	}
+	for _, move := range moves {
+		// コピーを作成（この手を読み終えたらプールに返す）
+		newBoard := b.pooledClone()
+
+		newBoard.ApplyLegal(move)
+		if newBoard.kingThreatened(b.CurrentTurn) || b.isUchifuzume(move) {
+			putBoard(newBoard)
+			continue
+		}
+		legal++
+
+		eval := s.childEval(newBoard, depth, alpha, beta, maximizing)
+		putBoard(newBoard)
+
+		if (maximizing && eval > bestEval) || (!maximizing && eval < bestEval) {
+			bestEval = eval
+			moveCopy := move
+			bestMove = &moveCopy
+		}
+
+		if maximizing {
+			alpha = max(alpha, eval)
+		} else {
+			beta = min(beta, eval)
+		}
+		if beta <= alpha {
+			break
+		}
+	}
+
+	if legal == 0 {
+		// 詰み: 早く詰ませる手ほど高く評価する
+		if b.CurrentTurn == First {
+			return -mateScore - depth, nil
+		}
+		return mateScore + depth, nil
+	}
+	return bestEval, bestMove
+}
+
+// 手を指した後の局面nextの評価値（depthとmaximizingは指す前の局面のもの）
+// 探索中の手順に同じ局面が現れていれば千日手とみなす。
+func (s *searcher) childEval(next *Board, depth, alpha, beta int, maximizing bool) int {
+	if c, winner := next.winCondition(); c != nil {
+		// トライなどで勝ち: 詰みと同じく早く勝つ手ほど高く評価する
+		if winner == Second {
+			return -mateScore - depth
+		}
+		return mateScore + depth
+	}
+	if s.seen == nil {
+		eval, _ := s.minimax(next, depth-1, alpha, beta, !maximizing)
+		return eval
+	}
+	key := next.positionKey()
+	if s.seen[key] > 0 {
+		return s.drawScore()
+	}
+	s.seen[key]++
+	eval, _ := s.minimax(next, depth-1, alpha, beta, !maximizing)
+	s.seen[key]--
+	return eval
+}
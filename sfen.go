@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SFEN形式の駒の表記（先手は大文字、後手は小文字）
+var sfenPieces = map[PieceType]string{
+	King:           "K",
+	Gold:           "G",
+	Silver:         "S",
+	Bishop:         "B",
+	Rook:           "R",
+	Pawn:           "P",
+	PromotedSilver: "+S",
+	PromotedBishop: "+B",
+	PromotedRook:   "+R",
+	PromotedPawn:   "+P",
+}
+
+// SFEN形式の持ち駒の並び順
+var sfenHandOrder = []PieceType{Rook, Bishop, Gold, Silver, Pawn}
+
+// SFEN形式の局面（plyは次に指す手の手数）
+// 段は上（一段目）から、筋は左（5筋）から並べる。
+func (b *Board) SFEN(ply int) string {
+	var sb strings.Builder
+	for r := 0; r < b.Size(); r++ {
+		if r > 0 {
+			sb.WriteByte('/')
+		}
+		empty := 0
+		for c := 0; c < b.Size(); c++ {
+			p := b.Cells[r][c]
+			if p.Owner == None {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fmt.Fprintf(&sb, "%d", empty)
+				empty = 0
+			}
+			sym := sfenPieces[p.Type]
+			if p.Owner == Second {
+				sym = strings.ToLower(sym)
+			}
+			sb.WriteString(sym)
+		}
+		if empty > 0 {
+			fmt.Fprintf(&sb, "%d", empty)
+		}
+	}
+
+	turn := "b"
+	if b.CurrentTurn == Second {
+		turn = "w"
+	}
+	hand := sfenHand(b.FirstHand, false) + sfenHand(b.SecondHand, true)
+	if hand == "" {
+		hand = "-"
+	}
+	return fmt.Sprintf("%s %s %s %d", sb.String(), turn, hand, ply)
+}
+
+// SFEN形式の持ち駒（例: 2PG）
+func sfenHand(hand []PieceType, lower bool) string {
+	counts := make(map[PieceType]int)
+	for _, p := range hand {
+		counts[p]++
+	}
+	var sb strings.Builder
+	for _, pType := range sfenHandOrder {
+		n := counts[pType]
+		if n == 0 {
+			continue
+		}
+		if n > 1 {
+			fmt.Fprintf(&sb, "%d", n)
+		}
+		sym := sfenPieces[pType]
+		if lower {
+			sym = strings.ToLower(sym)
+		}
+		sb.WriteString(sym)
+	}
+	return sb.String()
+}
+
+// SFEN形式の局面を読み込み（手数は無視する）
+func ParseSFEN(s string) (*Board, error) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf(tr("SFENの形式が不正です: %s"), s)
+	}
+	rows := strings.Split(fields[0], "/")
+	b, err := newBoardOfSize(len(rows), nil)
+	if err != nil {
+		return nil, err
+	}
+	for r, row := range rows {
+		c := 0
+		promoted := false
+		for _, ch := range row {
+			switch {
+			case ch >= '1' && ch <= '9':
+				c += int(ch - '0')
+				continue
+			case ch == '+':
+				promoted = true
+				continue
+			}
+			pType, owner, ok := sfenPiece(ch)
+			if !ok || c >= b.Size() {
+				return nil, fmt.Errorf(tr("%s段目が不正です: %s"), rankNames[r], row)
+			}
+			if promoted {
+				pType = promotedType(pType)
+				promoted = false
+			}
+			b.Cells[r][c] = Piece{pType, owner}
+			c++
+		}
+		if c != b.Size() || promoted {
+			return nil, fmt.Errorf(tr("%s段目が不正です: %s"), rankNames[r], row)
+		}
+	}
+
+	switch fields[1] {
+	case "b":
+		b.CurrentTurn = First
+	case "w":
+		b.CurrentTurn = Second
+	default:
+		return nil, fmt.Errorf(tr("手番が不正です: %s"), fields[1])
+	}
+
+	if fields[2] != "-" {
+		count := 0
+		for _, ch := range fields[2] {
+			if ch >= '0' && ch <= '9' {
+				count = count*10 + int(ch-'0')
+				continue
+			}
+			pType, owner, ok := sfenPiece(ch)
+			if !ok || pType == King {
+				return nil, fmt.Errorf(tr("持ち駒が不正です: %s"), fields[2])
+			}
+			if count == 0 {
+				count = 1
+			}
+			for i := 0; i < count; i++ {
+				if owner == First {
+					b.FirstHand = append(b.FirstHand, pType)
+				} else {
+					b.SecondHand = append(b.SecondHand, pType)
+				}
+			}
+			count = 0
+		}
+	}
+	return b, nil
+}
+
+// SFEN形式の駒の文字から駒の種類と持ち主を取得
+func sfenPiece(ch rune) (PieceType, Player, bool) {
+	owner := First
+	if ch >= 'a' && ch <= 'z' {
+		owner = Second
+		ch -= 'a' - 'A'
+	}
+	for pType, sym := range sfenPieces {
+		if sym == string(ch) {
+			return pType, owner, true
+		}
+	}
+	return Empty, None, false
+}
+
+// 対局を始められる局面か（玉が1枚ずつ、手番でない側に王手がかかっていない、二歩や行き所のない駒がない）
+func (b *Board) checkPosition() error {
+	var kings [3]int
+	for c := 0; c < b.Size(); c++ {
+		var pawns [3]int
+		for r := 0; r < b.Size(); r++ {
+			p := b.Cells[r][c]
+			switch {
+			case p.Owner == None:
+				continue
+			case p.Type == King:
+				kings[p.Owner]++
+			case p.Type == Pawn:
+				pawns[p.Owner]++
+			}
+			if b.rules().MustPromote(p, r) {
+				return fmt.Errorf(tr("行き所のない駒があります: %d%s"), c+1, rankNames[r])
+			}
+		}
+		if pawns[First] > 1 || pawns[Second] > 1 {
+			return fmt.Errorf(tr("二歩があります: %d筋"), c+1)
+		}
+	}
+	if kings[First] != 1 || kings[Second] != 1 {
+		return errors.New(tr("玉は先手と後手に1枚ずつ必要です"))
+	}
+	if b.kingThreatened(b.CurrentTurn.Opponent()) {
+		return errors.New(tr("手番でない側の玉に王手がかかっています"))
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import "math/rand"
+
+// 駒の配置をランダムにした初期局面（後手は先手と点対称に並べる）
+// 玉は端の筋に置き、歩は玉の前に置く。残りの金・銀・角・飛は一段目の空いた筋に並べる。
+func NewShuffledBoard(rng *rand.Rand) *Board {
+	others := []PieceType{Gold, Silver, Bishop, Rook}
+	b := newEmptyBoard(len(others) + 1)
+	last := b.Size() - 1
+
+	back := make([]PieceType, b.Size())
+	kingCol := 0
+	if rng.Intn(2) == 1 {
+		kingCol = last
+	}
+	back[kingCol] = King
+	rng.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+	for col := range back {
+		if col != kingCol {
+			back[col], others = others[0], others[1:]
+		}
+	}
+
+	for col, pType := range back {
+		b.Cells[last][col] = Piece{pType, First}
+		b.Cells[0][last-col] = Piece{pType, Second}
+	}
+	b.Cells[last-1][kingCol] = Piece{Pawn, First}
+	b.Cells[1][last-kingCol] = Piece{Pawn, Second}
+	return b
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strings"
+	"time"
+)
+
+// SPSAの設定
+type spsaOptions struct {
+	Iterations   int     // 反復の回数
+	Pairs        int     // 1回の反復で先後を入れ替えて指す対局の組の数
+	Depth        int     // 対局で使う探索深度
+	Nodes        int     // 対局で1手に探索する局面の数の上限（0なら制限しない）
+	MaxPlies     int     // この手数で終わらなければ引き分けとする
+	OpeningPlies int     // 初期局面からランダムに指す手数（対局ごとに違う局面から始める）
+	Perturbation float64 // 駒の価値を試しに動かす量（持ち駒の割合はその1/10の%）
+	LearningRate float64 // 1回目の反復で、勝ち越した側へ動かす量（Perturbationに対する倍率）
+	Seed         int64   // 乱数の種
+	Log          io.Writer
+}
+
+// SPSAの利得の減り方（Spallによる標準の指数）
+const (
+	spsaAlpha = 0.602
+	spsaGamma = 0.101
+)
+
+// パラメータを試しに動かす量の単位（持ち駒の割合は%なので駒の価値より小さく動かす）
+func spsaScale(name string) float64 {
+	if name == "hand_percent" {
+		return 0.1
+	}
+	return 1
+}
+
+// 2つの評価パラメータのAIを対局させ、plusの側から見た得点（勝ち1、引き分け0.5、負け0）を返す
+// 開始局面はstart。plusFirstならplusが先手。
+func spsaGame(start *Board, plus, minus *EvalParams, plusFirst bool, opts spsaOptions) float64 {
+	sente, gote := plus, minus
+	if !plusFirst {
+		sente, gote = minus, plus
+	}
+	g := NewGame(strings.NewReader(""), io.Discard, systemTime{})
+	g.Quiet = true
+	g.SetPosition(start.Clone())
+	g.UseMatch(&MatchConfig{
+		Sente: EngineConfig{Depth: opts.Depth, Nodes: opts.Nodes, eval: sente},
+		Gote:  EngineConfig{Depth: opts.Depth, Nodes: opts.Nodes, eval: gote},
+	})
+	g.OnMove(func(MoveEvent) {
+		if len(g.Record.Moves) >= opts.MaxPlies {
+			g.Quit()
+		}
+	})
+	g.Run()
+
+	switch g.Result.Outcome {
+	case SenteWin:
+		if plusFirst {
+			return 1
+		}
+		return 0
+	case GoteWin:
+		if plusFirst {
+			return 0
+		}
+		return 1
+	}
+	return 0.5 // 千日手と、手数の上限で打ち切った対局
+}
+
+// 初期局面からランダムにplies手指した局面（途中で終局したら指せたところまで）
+func spsaOpening(rng *rand.Rand, plies int) *Board {
+	b := NewBoard()
+	for i := 0; i < plies; i++ {
+		moves := b.GetAllLegalMoves()
+		if len(moves) == 0 {
+			break
+		}
+		b.ApplyLegal(moves[rng.Intn(len(moves))])
+	}
+	return b
+}
+
+// SPSA: 全パラメータを同時にランダムな向きへ±cだけ動かした2つのAIを対局させ、
+// 勝ち越した側へパラメータを動かすことを繰り返す。
+func spsaTune(start *EvalParams, opts spsaOptions) *EvalParams {
+	rng := rand.New(rand.NewSource(opts.Seed))
+	names := tuneNames(start)
+	theta := make([]float64, len(names))
+	for i, v := range start.tuneValues(names) {
+		theta[i] = float64(v)
+	}
+	round := func(xs []float64) []int {
+		values := make([]int, len(xs))
+		for i, x := range xs {
+			values[i] = int(math.Round(x))
+		}
+		return values
+	}
+
+	stability := float64(opts.Iterations) / 10 // 最初の数回で大きく動きすぎないようにする
+	for k := 1; k <= opts.Iterations; k++ {
+		a := opts.LearningRate * math.Pow(stability+1, spsaAlpha) / math.Pow(float64(k)+stability, spsaAlpha)
+		c := opts.Perturbation / math.Pow(float64(k), spsaGamma)
+
+		delta := make([]float64, len(names))
+		plus := make([]float64, len(names))
+		minus := make([]float64, len(names))
+		for i, name := range names {
+			delta[i] = float64(rng.Intn(2)*2 - 1)
+			step := c * spsaScale(name) * delta[i]
+			plus[i], minus[i] = theta[i]+step, theta[i]-step
+		}
+		plusParams := start.withTuneValues(names, round(plus))
+		minusParams := start.withTuneValues(names, round(minus))
+
+		score := 0.0
+		for p := 0; p < opts.Pairs; p++ {
+			opening := spsaOpening(rng, opts.OpeningPlies)
+			score += spsaGame(opening, plusParams, minusParams, true, opts)
+			score += spsaGame(opening, plusParams, minusParams, false, opts)
+		}
+		// plusの勝ち越し（-1〜1）。勝ち越した側へ、動かした量に比例して動かす
+		r := 2*score/float64(2*opts.Pairs) - 1
+		for i, name := range names {
+			theta[i] = math.Max(theta[i]+a*c*spsaScale(name)*r*delta[i], 0)
+		}
+
+		fmt.Fprintf(opts.Log, tr("%d回目: 勝ち越し %+.2f"), k, r)
+		for i, name := range names {
+			fmt.Fprintf(opts.Log, " %s=%.1f", name, theta[i])
+		}
+		fmt.Fprintln(opts.Log)
+	}
+	return start.withTuneValues(names, round(theta))
+}
+
+// spsa サブコマンド: AI同士の対局の勝ち負けから評価パラメータを調整する（SPSA）
+func runSPSA(args []string) int {
+	fs := flag.NewFlagSet("spsa", flag.ContinueOnError)
+	evalFile := fs.String("eval-file", "", "調整を始める評価パラメータのJSONファイル（省略すると既定値）")
+	out := fs.String("o", "", "調整した評価パラメータを書き出すJSONファイル（省略すると標準出力）")
+	iterations := fs.Int("iterations", 100, "反復の回数")
+	pairs := fs.Int("pairs", 2, "1回の反復で先後を入れ替えて指す対局の組の数")
+	depth := fs.Int("depth", 2, "対局で使う探索深度")
+	nodes := fs.Int("nodes", 2000, "対局で1手に探索する局面の数の上限（0なら制限しない）")
+	maxPlies := fs.Int("max-plies", 150, "この手数で終わらなければ引き分けとする")
+	openingPlies := fs.Int("opening-plies", 4, "対局ごとに初期局面からランダムに指す手数")
+	c := fs.Float64("c", 50, "駒の価値を試しに動かす量（持ち駒の割合はその1/10の%）")
+	a := fs.Float64("a", 1, "1回目の反復で、勝ち越した側へ動かす量（-c に対する倍率）")
+	seed := fs.Int64("seed", 0, "乱数の種（0なら時刻から決める）")
+	setUsage(fs, "使い方: mini-syogi spsa [-eval-file ファイル] [-o ファイル] [-iterations N] [-pairs N] [-depth N] [-nodes N] [-max-plies N] [-opening-plies N] [-c 量] [-a 倍率] [-seed N]")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *iterations < 1 || *pairs < 1 || *depth < 1 || *maxPlies < 1 || *nodes < 0 || *openingPlies < 0 || *c <= 0 || *a <= 0 {
+		fmt.Fprintln(os.Stderr, tr("-iterations・-pairs・-depth・-max-plies は1以上、-nodes と -opening-plies は0以上、-c と -a は正の値です"))
+		return exitUsage
+	}
+	start := DefaultEvalParams()
+	if *evalFile != "" {
+		p, err := loadEvalParams(*evalFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("評価パラメータを読み込めません:"), err)
+			return exitError
+		}
+		start = p
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	fmt.Fprintf(os.Stderr, tr("乱数の種: %d")+"\n", *seed)
+
+	tuned := spsaTune(start, spsaOptions{
+		Iterations:   *iterations,
+		Pairs:        *pairs,
+		Depth:        *depth,
+		Nodes:        *nodes,
+		MaxPlies:     *maxPlies,
+		OpeningPlies: *openingPlies,
+		Perturbation: *c,
+		LearningRate: *a,
+		Seed:         *seed,
+		Log:          os.Stderr,
+	})
+	write := func(w io.Writer) error { return writeEvalParams(w, tuned) }
+	var err error
+	if *out == "" {
+		err = write(os.Stdout)
+	} else {
+		err = writeFile(*out, write)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("評価パラメータを書き出せません:"), err)
+		return exitError
+	}
+	return 0
+}
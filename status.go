@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 千日手になる同一局面の出現回数
+const repetitionCount = 4
+
+// 手番の側が王手をかけられているか
+func (b *Board) InCheck() bool {
+	return b.kingThreatened(b.CurrentTurn)
+}
+
+// 手番の側が詰んでいるか（王手をかけられていて合法手がない）
+func (b *Board) IsCheckmate() bool {
+	return b.InCheck() && !b.hasLegalMove(true)
+}
+
+// 手番の側が王手をかけられていないのに合法手がないか
+// 将棋では指せる手がない側の負けになるので、IsGameOver ではこの場合も負けとして扱う。
+func (b *Board) IsStalemate() bool {
+	return !b.InCheck() && !b.hasLegalMove(true)
+}
+
+// 局面を識別する文字列（盤面・持ち駒・手番が同じなら同じになる）
+func (b *Board) positionKey() string {
+	var sb strings.Builder
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			p := b.Cells[r][c]
+			fmt.Fprintf(&sb, "%d%d,", p.Owner, p.Type)
+		}
+	}
+	for _, hand := range [][]PieceType{b.FirstHand, b.SecondHand} {
+		counts := make([]int, PromotedPawn+1)
+		for _, p := range hand {
+			counts[p]++
+		}
+		fmt.Fprintf(&sb, "%v", counts)
+	}
+	fmt.Fprintf(&sb, "%d", b.CurrentTurn)
+	return sb.String()
+}
+
+// 棋譜に現れた各局面の出現回数（開始局面と最後の局面を含む）
+// 初めて呼んだときに開始局面から数え、その後は Add で指した手の分だけ数え足す。
+func (r *Record) positionCounts() map[string]int {
+	if r.counts == nil {
+		r.last = r.Initial.Clone()
+		r.counts = map[string]int{r.last.positionKey(): 1}
+		for _, m := range r.Moves {
+			r.last.ApplyLegal(m)
+			r.counts[r.last.positionKey()]++
+		}
+	}
+	return r.counts
+}
+
+// 対局中に現れた各局面の出現回数（開始局面と現局面を含む。変更しないこと）
+func (g *Game) positionCounts() map[string]int {
+	return g.Record.positionCounts()
+}
+
+// 千日手（同一局面が4回現れた）で引き分けか
+// 局面の履歴が必要なので、盤面ではなく対局に対して判定する。
+// 4回目の出現で終局するので、現局面の出現回数だけを見ればよい。
+func (g *Game) IsDraw() bool {
+	return g.positionCounts()[g.Board.positionKey()] >= repetitionCount
+}
+
+// 対局中の局面で王手がかかっているか
+func (g *Game) InCheck() bool {
+	return g.Board.InCheck()
+}
+
+// 対局中の局面で詰んでいるか
+func (g *Game) IsCheckmate() bool {
+	return g.Board.IsCheckmate()
+}
+
+// 対局中の局面で王手なしに合法手がないか
+func (g *Game) IsStalemate() bool {
+	return g.Board.IsStalemate()
+}
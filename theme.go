@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// 盤面表示のテーマ（JSONの項目名は設定ファイルのテーマで使う）
+type Theme struct {
+	Symbols     map[PieceType]string `json:"-"`            // 駒の表記
+	Empty       string               `json:"empty"`        // 空きマス
+	FirstMark   string               `json:"first_mark"`   // 先手の駒の前に付ける印
+	SecondMark  string               `json:"second_mark"`  // 後手の駒の前に付ける印
+	Pad         string               `json:"pad"`          // 駒の後ろの余白
+	FirstColor  string               `json:"first_color"`  // 先手の駒の色（ANSIエスケープ、空なら色なし）
+	SecondColor string               `json:"second_color"` // 後手の駒の色
+	Header      string               `json:"header"`       // 筋の見出し
+	Top         string               `json:"top"`          // 上の罫線
+	Bottom      string               `json:"bottom"`       // 下の罫線
+	LeftSide    string               `json:"left_side"`    // 左の罫線
+	RightSide   string               `json:"right_side"`   // 右の罫線
+	LeftRank    bool                 `json:"left_rank"`    // 段の見出しを左にも表示する
+	Footer      bool                 `json:"footer"`       // 筋の見出しを下にも表示する
+	LastMark    string               `json:"last_mark"`    // 直前の手のマスで駒の後ろの余白の代わりに付ける印（空なら、端末では色を反転して示す）
+}
+
+// 駒の漢字表記
+var kanjiSymbols = map[PieceType]string{
+	King:           "玉",
+	Gold:           "金",
+	Silver:         "銀",
+	Bishop:         "角",
+	Rook:           "飛",
+	Pawn:           "歩",
+	PromotedSilver: "全",
+	PromotedBishop: "馬",
+	PromotedRook:   "龍",
+	PromotedPawn:   "と",
+}
+
+// 駒のアルファベット表記（先手は大文字、後手は小文字で表示）
+var letterSymbols = map[PieceType]string{
+	King:           "K",
+	Gold:           "G",
+	Silver:         "S",
+	Bishop:         "B",
+	Rook:           "R",
+	Pawn:           "P",
+	PromotedSilver: "N",
+	PromotedBishop: "H",
+	PromotedRook:   "D",
+	PromotedPawn:   "T",
+}
+
+// 段の漢数字（maxBoardSize段まで）
+var rankNames = []string{"一", "二", "三", "四", "五", "六", "七", "八", "九"}
+
+// テーマの筋の見出しと罫線が書かれている筋の数（ほかの大きさの盤では、これを元に作り直す）
+const themeFiles = 5
+
+// 組み込みテーマ
+var themes = map[string]*Theme{
+	"default": {
+		Symbols:    kanjiSymbols,
+		Empty:      " ． ",
+		FirstMark:  " ",
+		SecondMark: "v",
+		Pad:        " ",
+		Header:     "  １ ２ ３ ４ ５",
+		Top:        "┌─────────────┐",
+		Bottom:     "└─────────────┘",
+		LeftSide:   "│",
+		RightSide:  "│",
+		LastMark:   "<",
+	},
+	// 狭い端末向け: 罫線なしで1マス3桁
+	"minimal": {
+		Symbols:    kanjiSymbols,
+		Empty:      " ・",
+		FirstMark:  " ",
+		SecondMark: "v",
+		Header:     " １ ２ ３ ４ ５",
+		RightSide:  " ",
+	},
+	// 四辺すべてに座標を表示
+	"dense": {
+		Symbols:    kanjiSymbols,
+		Empty:      " ． ",
+		FirstMark:  " ",
+		SecondMark: "v",
+		Pad:        " ",
+		Header:     "    １ ２ ３ ４ ５",
+		Top:        "  ┌─────────────┐",
+		Bottom:     "  └─────────────┘",
+		LeftSide:   "│",
+		RightSide:  "│",
+		LeftRank:   true,
+		Footer:     true,
+		LastMark:   "<",
+	},
+	// 先手を青、後手を赤で表示
+	"color": {
+		Symbols:     kanjiSymbols,
+		Empty:       " ． ",
+		FirstMark:   " ",
+		SecondMark:  "v",
+		Pad:         " ",
+		FirstColor:  "\x1b[34m",
+		SecondColor: "\x1b[31m",
+		Header:      "  １ ２ ３ ４ ５",
+		Top:         "┌─────────────┐",
+		Bottom:      "└─────────────┘",
+		LeftSide:    "│",
+		RightSide:   "│",
+	},
+}
+
+// 現在のテーマ
+var currentTheme = themes["default"]
+
+// テーマの設定ファイルの場所
+func themesPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "mini-syogi", "themes.json"), nil
+}
+
+// 設定ファイルのテーマを読み込んで組み込みテーマに加える（ファイルがなければ組み込みテーマだけ）
+// 各テーマは "base" の組み込みテーマ（省略すると default）を元に、書いた項目だけを変える。
+// 駒の表記は "symbols" にCSA形式の駒の表記ごとに書く（例: {"OU": "王"}）。同じ名前の組み込みテーマは置き換える。
+func loadThemes(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	loaded := make(map[string]*Theme, len(file))
+	for name, raw := range file {
+		var head struct {
+			Base    string            `json:"base"`
+			Symbols map[string]string `json:"symbols"`
+		}
+		if err := json.Unmarshal(raw, &head); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if head.Base == "" {
+			head.Base = "default"
+		}
+		base, ok := themes[head.Base]
+		if !ok {
+			return fmt.Errorf(tr("%s: 元にするテーマがありません: %s"), name, head.Base)
+		}
+		t := *base
+		t.Symbols = maps.Clone(base.Symbols)
+		if err := json.Unmarshal(raw, &t); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for code, s := range head.Symbols {
+			pType, ok := csaPieceType(code)
+			if !ok {
+				return fmt.Errorf(tr("%s: 駒の種類が不正です: %s"), name, code)
+			}
+			t.Symbols[pType] = s
+		}
+		loaded[name] = &t
+	}
+	maps.Copy(themes, loaded)
+	currentTheme = themes["default"]
+	return nil
+}
+
+// 標準出力が端末か（端末でなければ、パイプやファイルに色の反転のエスケープを出さない）
+var ansiOutput = isTerminal(os.Stdout)
+
+// ファイルが端末か
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// 駒をアルファベットで表示するか（テーマとは独立に切り替える）
+var useLetters bool
+
+// テーマ名の一覧
+func themeNames() []string {
+	names := make([]string, 0, len(themes))
+	for name := range themes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// テーマを選択
+func setTheme(name string) error {
+	t, ok := themes[name]
+	if !ok {
+		return fmt.Errorf(tr("不明なテーマです: %s（%s）"), name, strings.Join(themeNames(), ", "))
+	}
+	currentTheme = t
+	return nil
+}
+
+// 盤の筋の数に合わせた筋の見出し（テーマの見出しの「１」〜「５」を、1筋から筋の数までの数字に並べ直す）
+func (t *Theme) header(size int) string {
+	first := strings.Index(t.Header, kifFiles[0])
+	last := strings.Index(t.Header, kifFiles[themeFiles-1])
+	if size == themeFiles || first < 0 || last < first {
+		return t.Header
+	}
+	sep := ""
+	if second := strings.Index(t.Header, kifFiles[1]); second > first {
+		sep = t.Header[first+len(kifFiles[0]) : second]
+	}
+	return t.Header[:first] + strings.Join(kifFiles[:size], sep) + t.Header[last+len(kifFiles[themeFiles-1]):]
+}
+
+// 盤の筋の数に合わせた罫線（テーマの罫線で最も長く続く文字を線とみなし、筋の数に比例した長さにする）
+func (t *Theme) rule(line string, size int) string {
+	runes := []rune(line)
+	start, length := 0, 0
+	for i := 0; i < len(runes); {
+		j := i
+		for j < len(runes) && runes[j] == runes[i] {
+			j++
+		}
+		if j-i > length {
+			start, length = i, j-i
+		}
+		i = j
+	}
+	if size == themeFiles || length == 0 {
+		return line
+	}
+	n := (length*size + themeFiles/2) / themeFiles
+	return string(runes[:start]) + strings.Repeat(string(runes[start]), n) + string(runes[start+length:])
+}
+
+// マスの文字表現
+func (t *Theme) cell(p Piece) string {
+	if p.Owner == None {
+		return t.Empty
+	}
+	return t.pieceCell(p, "")
+}
+
+// 直前の手の移動元・移動先のマスの文字表現（cellで作ったマスに印を付ける）
+// 印のないテーマ（minimal・color）は、端末でなければ印を付けない（直前の手は盤面の下の表示で分かる）。
+func (t *Theme) lastMoveCell(s string) string {
+	if t.LastMark != "" {
+		return strings.TrimSuffix(s, t.Pad) + t.LastMark
+	}
+	if !ansiOutput {
+		return s
+	}
+	return "\x1b[7m" + strings.ReplaceAll(s, "\x1b[0m", "\x1b[0m\x1b[7m") + "\x1b[0m"
+}
+
+// 印を付けたマスの文字表現（空きマスは「＊」、駒は所有者の印の代わりにmark）
+func (t *Theme) markedCell(p Piece, mark string) string {
+	if p.Owner == None {
+		return strings.NewReplacer("．", "＊", "・", "＊").Replace(t.Empty)
+	}
+	return t.pieceCell(p, mark)
+}
+
+// 駒の文字表現（markが空でなければ所有者の印の代わりに表示する）
+func (t *Theme) pieceCell(p Piece, mark string) string {
+	owner, color := t.FirstMark, t.FirstColor
+	if p.Owner == Second {
+		owner, color = t.SecondMark, t.SecondColor
+	}
+	if mark == "" {
+		mark = owner
+	}
+	s := mark + t.Symbols[p.Type]
+	if useLetters {
+		// 所有者は大文字/小文字と「*」で区別する（例: "R " と "r*"）
+		if mark == owner {
+			mark = " "
+		}
+		if p.Owner == First {
+			s = mark + letterSymbols[p.Type] + " "
+		} else {
+			s = mark + strings.ToLower(letterSymbols[p.Type]) + "*"
+		}
+	}
+	if color != "" {
+		s = color + s + "\x1b[0m"
+	}
+	return s + t.Pad
+}
+
+// 駒の種類の表記（持ち駒などで使う）
+func (t *Theme) pieceName(pType PieceType) string {
+	if useLetters {
+		return letterSymbols[pType]
+	}
+	return t.Symbols[pType]
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// マスに利いている指定したプレイヤーの駒のマス
+func (b *Board) Attackers(row, col int, by Player) [][2]int {
+	var squares [][2]int
+	for r := 0; r < b.Size(); r++ {
+		for c := 0; c < b.Size(); c++ {
+			if b.Cells[r][c].Owner != by {
+				continue
+			}
+			b.forEachAttackedSquare(r, c, func(ar, ac int) {
+				if ar == row && ac == col {
+					squares = append(squares, [2]int{r, c})
+				}
+			})
+		}
+	}
+	return squares
+}
+
+// threats コマンド: 手番の側から見た相手の利きと狙いを表示
+// 盤面では相手の利きがある空きマスを「＊」、相手の駒が利いている自分の駒を「!」で示す。
+func (g *Game) printThreats(w io.Writer) {
+	board := g.Board
+	player := board.CurrentTurn
+	opp := player.Opponent()
+	control := board.AttackMap(opp)
+
+	fmt.Fprintln(w, "\n"+tr("相手の利き（＊: 相手が利かせているマス、!: 相手の駒が利いている自分の駒）"))
+	view := board
+	if g.flippedView() {
+		view = board.flipped()
+	}
+	view.displayCells(w, func(r, c int) string {
+		br, bc := r, c
+		if view != board {
+			br, bc = board.flipSquare(r, c)
+		}
+		p := board.Cells[br][bc]
+		if control[br][bc] > 0 && p.Owner != opp {
+			return currentTheme.markedCell(p, "!")
+		}
+		return currentTheme.cell(p)
+	})
+
+	for r := 0; r < board.Size(); r++ {
+		for c := 0; c < board.Size(); c++ {
+			p := board.Cells[r][c]
+			if p.Owner != player || control[r][c] == 0 {
+				continue
+			}
+			var attackers []string
+			for _, sq := range board.Attackers(r, c, opp) {
+				a := board.Cells[sq[0]][sq[1]]
+				attackers = append(attackers, g.squareText(sq[0], sq[1])+currentTheme.pieceName(a.Type))
+			}
+			fmt.Fprintf(w, tr("%s%s ← %s")+"\n",
+				g.squareText(r, c), currentTheme.pieceName(p.Type), strings.Join(attackers, tr("、")))
+		}
+	}
+
+	if board.InCheck() {
+		fmt.Fprintln(w, tr("王手されています"))
+	} else if m := board.MateThreat(); m != nil {
+		fmt.Fprintf(w, tr("詰めろです（相手の狙い: %s）")+"\n", g.moveText(m))
+	} else if m := board.Threat(); m != nil {
+		fmt.Fprintf(w, tr("相手の狙い: %s")+"\n", g.moveText(m))
+	} else {
+		fmt.Fprintln(w, tr("相手にすぐの狙いはありません"))
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// 時間管理
+const (
+	maxSearchDepth = 8  // 持ち時間がある場合の最大探索深度
+	minMovesToGo   = 10 // 残り手数の見積もりの下限
+)
+
+// 1手に使う時間の目安と上限を決める
+// 目安は残り時間を今後の手数の見積もりで割った値、上限は目安の4倍（残り時間の1/3まで）。
+func allocateTime(remaining time.Duration, ply int) (soft, hard time.Duration) {
+	movesToGo := 30 - ply/2
+	if movesToGo < minMovesToGo {
+		movesToGo = minMovesToGo
+	}
+	soft = remaining / time.Duration(movesToGo)
+	hard = soft * 4
+	if limit := remaining / 3; hard > limit {
+		hard = limit
+	}
+	return soft, hard
+}
+
+// 持ち時間と局面の数の上限に合わせて反復深化で探索
+// 上限の時間を過ぎるか局面の数の上限に達したら探索を打ち切り、最後に読み切った深さの最善手を返す。
+// 持ち時間がなければDepthまで読む。
+func (s *searcher) searchIterative(b *Board) SearchResult {
+	start := s.now.Now()
+	timed := s.opts.Remaining > 0
+	maxDepth := s.opts.Depth
+	parent := s.ctx
+	hardCtx := parent
+	var soft time.Duration
+	if timed {
+		var hard time.Duration
+		soft, hard = allocateTime(s.opts.Remaining, s.opts.Ply)
+		s.logf(tr("持ち時間 目安=%v 上限=%v"), soft, hard)
+		if b.InCheck() {
+			// 王手をかけられている局面は読みを誤ると負けに直結するので長めに考える
+			soft *= 2
+		}
+		var cancel context.CancelFunc
+		hardCtx, cancel = context.WithCancel(parent)
+		defer cancel()
+		defer s.now.AfterFunc(hard, cancel).Stop()
+		maxDepth = maxSearchDepth
+	}
+
+	var best SearchResult
+	for depth := 1; depth <= maxDepth; depth++ {
+		// 深さ1は必ず読み切る（時間や局面の数が足りなくても手を返せるようにする）
+		s.ctx, s.limit = hardCtx, s.opts.Nodes
+		if depth == 1 {
+			s.ctx, s.limit = parent, 0
+		}
+		eval, move := s.searchRoot(b, depth)
+		s.logIteration(b, depth, eval, move, start)
+		if s.aborted() {
+			break // 打ち切った深さの結果は使わない
+		}
+
+		// 最善手が変わった（読みが安定していない）ときは目安を延ばす
+		limit := soft
+		if best.Move != nil && move != nil && !movesEqual(best.Move, move) {
+			limit = soft * 3 / 2
+		}
+		best = SearchResult{move, eval, depth}
+		if move == nil {
+			break
+		}
+
+		// 次の深さは少なくともここまでの倍はかかるので、目安を超えそうなら打ち切る
+		if timed && s.now.Now().Sub(start)*2 > limit {
+			break
+		}
+	}
+	s.ctx, s.limit = parent, 0
+	return best
+}
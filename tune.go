@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// 調整に使う局面と、その局面から指した対局の結果（先手から見た得点: 勝ち1、引き分け0.5、負け0）
+type tuneSample struct {
+	board  *Board
+	result float64
+}
+
+// 棋譜の結果（先手から見た得点）。勝敗のつかなかった棋譜はfalse
+func recordResult(r *Record) (float64, bool) {
+	switch recordWinner(r) {
+	case First:
+		return 1, true
+	case Second:
+		return 0, true
+	}
+	if r.End == "%SENNICHITE" {
+		return 0.5, true
+	}
+	return 0, false
+}
+
+// 棋譜の局面を調整用に集める（王手をかけられている局面は駒の損得が落ち着いていないので除く）
+func recordSamples(r *Record) []tuneSample {
+	result, ok := recordResult(r)
+	if !ok {
+		return nil
+	}
+	var samples []tuneSample
+	b := r.Initial.Clone()
+	for ply := 0; ; ply++ {
+		if !b.InCheck() {
+			samples = append(samples, tuneSample{b.Clone(), result})
+		}
+		if ply == len(r.Moves) {
+			return samples
+		}
+		b.ApplyLegal(r.Moves[ply])
+	}
+}
+
+// 評価値から先手の得点の見込みを求める（Kで評価値の尺度を合わせる）
+func winProbability(score int, k float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*float64(score)/400))
+}
+
+// 局面の結果と、評価値から見込んだ得点との平均二乗誤差
+func tuneError(samples []tuneSample, p *EvalParams, k float64) float64 {
+	sum := 0.0
+	for _, s := range samples {
+		d := s.result - winProbability(s.board.evaluateWith(p), k)
+		sum += d * d
+	}
+	return sum / float64(len(samples))
+}
+
+// 誤差が最も小さくなるKを三分探索で求める
+func fitTuneScale(samples []tuneSample, p *EvalParams) float64 {
+	lo, hi := 0.01, 10.0
+	for i := 0; i < 60; i++ {
+		m1, m2 := lo+(hi-lo)/3, hi-(hi-lo)/3
+		if tuneError(samples, p, m1) < tuneError(samples, p, m2) {
+			hi = m2
+		} else {
+			lo = m1
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// 調整するパラメータの名前（玉を除く駒のCSA形式の表記と hand_percent）
+// 玉は両方の側に1枚ずつあり評価値に影響しないので調整しない。
+func tuneNames(p *EvalParams) []string {
+	var names []string
+	for code := range p.PieceValues {
+		if code != "OU" {
+			names = append(names, code)
+		}
+	}
+	sort.Strings(names)
+	return append(names, "hand_percent")
+}
+
+// パラメータの値を名前の順に並べる
+func (p *EvalParams) tuneValues(names []string) []int {
+	values := make([]int, len(names))
+	for i, name := range names {
+		if name == "hand_percent" {
+			values[i] = p.HandPercent
+		} else {
+			values[i] = p.PieceValues[name]
+		}
+	}
+	return values
+}
+
+// 名前の順に並べた値でパラメータを作る（値は0未満にしない。調整しない駒の価値はbaseのまま）
+func (base *EvalParams) withTuneValues(names []string, values []int) *EvalParams {
+	p := &EvalParams{PieceValues: map[string]int{}, HandPercent: base.HandPercent}
+	for code, v := range base.PieceValues {
+		p.PieceValues[code] = v
+	}
+	for i, name := range names {
+		v := max(values[i], 0)
+		if name == "hand_percent" {
+			p.HandPercent = v
+		} else {
+			p.PieceValues[name] = v
+		}
+	}
+	p.resolve()
+	return p
+}
+
+// Texel法の局所探索: パラメータを1つずつ±stepだけ動かし、誤差が減る間は続ける
+// 1巡で誤差が減らなくなるか、iterations巡したら終える。巡ごとの誤差をlogに書く。
+func texelTune(samples []tuneSample, start *EvalParams, k float64, step, iterations int, log io.Writer) *EvalParams {
+	names := tuneNames(start)
+	values := start.tuneValues(names)
+	best := tuneError(samples, start, k)
+	fmt.Fprintf(log, tr("調整前: 誤差 %.6f")+"\n", best)
+	for it := 1; it <= iterations; it++ {
+		improved := false
+		for i, name := range names {
+			delta := step
+			if name == "hand_percent" {
+				delta = 1 // 割合（%）は駒の価値より細かく動かす
+			}
+			for _, d := range []int{delta, -delta} {
+				trial := append([]int{}, values...)
+				trial[i] += d
+				if trial[i] < 0 {
+					continue
+				}
+				if e := tuneError(samples, start.withTuneValues(names, trial), k); e < best {
+					values, best, improved = trial, e, true
+					break
+				}
+			}
+		}
+		fmt.Fprintf(log, tr("%d巡目: 誤差 %.6f")+"\n", it, best)
+		if !improved {
+			break
+		}
+	}
+	return start.withTuneValues(names, values)
+}
+
+// 評価パラメータをJSONで書き出す
+func writeEvalParams(w io.Writer, p *EvalParams) error {
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return err
+}
+
+// tune サブコマンド: 棋譜の勝敗に合うように評価パラメータを調整する（Texel法）
+func runTune(args []string) int {
+	fs := flag.NewFlagSet("tune", flag.ContinueOnError)
+	evalFile := fs.String("eval-file", "", "調整を始める評価パラメータのJSONファイル（省略すると既定値）")
+	out := fs.String("o", "", "調整した評価パラメータを書き出すJSONファイル（省略すると標準出力）")
+	iterations := fs.Int("iterations", 100, "パラメータを一通り動かす回数の上限")
+	step := fs.Int("step", 10, "駒の価値を一度に動かす量")
+	k := fs.Float64("k", 0, "評価値から勝率を見込む尺度（0なら棋譜に合わせて求める）")
+	setUsage(fs, "使い方: mini-syogi tune [-eval-file ファイル] [-o ファイル] [-iterations N] [-step N] [-k K] <棋譜のフォルダ>")
+	if err := fs.Parse(args); err != nil {
+		return exitUsage
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+	if *iterations < 1 || *step < 1 || *k < 0 {
+		fmt.Fprintln(os.Stderr, tr("-iterations と -step は1以上、-k は0以上です"))
+		return exitUsage
+	}
+	start := DefaultEvalParams()
+	if *evalFile != "" {
+		p, err := loadEvalParams(*evalFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, tr("評価パラメータを読み込めません:"), err)
+			return exitError
+		}
+		start = p
+	}
+
+	var samples []tuneSample
+	used := 0
+	skipped, err := walkRecords(fs.Arg(0), func(r *Record) {
+		s := recordSamples(r)
+		if s == nil {
+			return
+		}
+		samples = append(samples, s...)
+		used++
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("棋譜を読み込めません:"), err)
+		return exitError
+	}
+	if len(samples) == 0 {
+		fmt.Fprintln(os.Stderr, tr("勝敗のついた棋譜がありません"))
+		return exitError
+	}
+	fmt.Fprintf(os.Stderr, tr("%d局の棋譜の%d局面を使います（読めなかった棋譜 %d局）")+"\n", used, len(samples), skipped)
+	if *k == 0 {
+		*k = fitTuneScale(samples, start)
+		fmt.Fprintf(os.Stderr, "K = %.4f\n", *k)
+	}
+
+	tuned := texelTune(samples, start, *k, *step, *iterations, os.Stderr)
+	write := func(w io.Writer) error { return writeEvalParams(w, tuned) }
+	if *out == "" {
+		err = write(os.Stdout)
+	} else {
+		err = writeFile(*out, write)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, tr("評価パラメータを書き出せません:"), err)
+		return exitError
+	}
+	return 0
+}
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// チュートリアルの盤面に置く駒
+type placed struct {
+	row, col int
+	piece    Piece
+}
+
+// チュートリアルの1課（文は日本語の原文で書き、表示するときに訳す）
+type lesson struct {
+	title  string
+	text   []string
+	setup  func() *Board               // 練習問題の局面（nilなら説明のみ）
+	task   string                      // 練習問題の指示
+	goal   func(b *Board, m Move) bool // 正解の手か（bは指す前の局面）
+	answer string                      // 正解の入力例
+}
+
+// 駒を並べた局面（先手番）
+func tutorialBoard(hand []PieceType, pieces ...placed) *Board {
+	b := newEmptyBoard(Minishogi{}.Size())
+	b.FirstHand = hand
+	for _, p := range pieces {
+		b.Cells[p.row][p.col] = p.piece
+	}
+	return b
+}
+
+// 指定したマスへの手か
+func movesTo(row, col int) func(*Board, Move) bool {
+	return func(_ *Board, m Move) bool {
+		return m.ToRow == row && m.ToCol == col
+	}
+}
+
+var lessons = []lesson{
+	{
+		title: "玉",
+		text: []string{
+			"玉は周囲8方向に1マスずつ動けます。",
+			"玉を取られると負けなので、相手の駒の利きがあるマスには動けません。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{3, 2, Piece{King, First}},
+				placed{0, 0, Piece{King, Second}})
+		},
+		task:   "玉を2三へ動かしてください",
+		goal:   movesTo(2, 1),
+		answer: "3423",
+	},
+	{
+		title: "金",
+		text: []string{
+			"金は前・斜め前・横・後ろに1マスずつ動けます（斜め後ろには動けません）。",
+			"相手の駒がいるマスに動くと、その駒を取って持ち駒にできます。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{3, 2, Piece{Gold, First}},
+				placed{2, 3, Piece{Pawn, Second}},
+				placed{4, 4, Piece{King, First}},
+				placed{0, 0, Piece{King, Second}})
+		},
+		task:   "金で4三の歩を取ってください",
+		goal:   movesTo(2, 3),
+		answer: "3443",
+	},
+	{
+		title: "銀",
+		text: []string{
+			"銀は前・斜め前・斜め後ろに1マスずつ動けます（横と後ろには動けません）。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{3, 2, Piece{Silver, First}},
+				placed{4, 1, Piece{Pawn, Second}},
+				placed{4, 4, Piece{King, First}},
+				placed{0, 0, Piece{King, Second}})
+		},
+		task:   "銀で2五の歩を取ってください",
+		goal:   movesTo(4, 1),
+		answer: "3425",
+	},
+	{
+		title: "角",
+		text: []string{
+			"角は斜め4方向に、駒にぶつかるまで何マスでも動けます。",
+			"成ると馬になり、さらに縦横に1マス動けるようになります。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{4, 0, Piece{Bishop, First}},
+				placed{0, 4, Piece{Gold, Second}},
+				placed{4, 4, Piece{King, First}},
+				placed{0, 2, Piece{King, Second}})
+		},
+		task:   "角で5一の金を取ってください",
+		goal:   movesTo(0, 4),
+		answer: "1551",
+	},
+	{
+		title: "飛",
+		text: []string{
+			"飛は縦横4方向に、駒にぶつかるまで何マスでも動けます。",
+			"成ると龍になり、さらに斜めに1マス動けるようになります。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{4, 4, Piece{Rook, First}},
+				placed{0, 4, Piece{Silver, Second}},
+				placed{4, 0, Piece{King, First}},
+				placed{0, 0, Piece{King, Second}})
+		},
+		task:   "飛で5一の銀を取ってください",
+		goal:   movesTo(0, 4),
+		answer: "5551",
+	},
+	{
+		title: "歩",
+		text: []string{
+			"歩は前に1マスだけ動けます。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{3, 2, Piece{Pawn, First}},
+				placed{4, 4, Piece{King, First}},
+				placed{0, 0, Piece{King, Second}})
+		},
+		task:   "歩を3三へ進めてください",
+		goal:   movesTo(2, 2),
+		answer: "3433",
+	},
+	{
+		title: "成り",
+		text: []string{
+			"5五将棋では、相手側の一番奥の段（先手なら一段目）が敵陣です。",
+			"銀・角・飛・歩は、敵陣に入るときに成ることができます。",
+			"歩が一段目に進むときは、それ以上動けなくなるので必ず成ります。",
+		},
+		setup: func() *Board {
+			return tutorialBoard(nil,
+				placed{1, 2, Piece{Silver, First}},
+				placed{4, 4, Piece{King, First}},
+				placed{0, 4, Piece{King, Second}})
+		},
+		task: "銀を3一へ動かして成ってください",
+		goal: func(_ *Board, m Move) bool {
+			return m.ToRow == 0 && m.ToCol == 2 && m.Promote
+		},
+		answer: "3231 のあと y",
+	},
+	{
+		title: "持ち駒を打つ",
+		text: []string{
+			"取った駒は持ち駒になり、自分の手番に空いているマスへ打てます。",
+			"ただし、同じ筋に自分の歩が2枚ある状態（二歩）にはできません。",
+			"入力は p33 のように、駒の記号（p=歩,s=銀,g=金,b=角,r=飛）とマスを続けます。",
+		},
+		setup: func() *Board {
+			return tutorialBoard([]PieceType{Pawn},
+				placed{4, 4, Piece{King, First}},
+				placed{0, 0, Piece{King, Second}})
+		},
+		task: "持ち駒の歩を3三に打ってください",
+		goal: func(_ *Board, m Move) bool {
+			return m.IsDrop && m.DropPiece == Pawn && m.ToRow == 2 && m.ToCol == 2
+		},
+		answer: "p33",
+	},
+	{
+		title: "5五将棋のルール",
+		text: []string{
+			"5五将棋は5×5の盤で、玉・金・銀・角・飛・歩を1枚ずつ使います。",
+			"相手の玉を詰ませたほうが勝ちです。",
+			"自分の玉が取られる手、二歩、行き所のない駒、打ち歩詰めは反則で指せません。",
+			"同じ局面が4回現れると千日手で引き分けです。",
+		},
+	},
+}
+
+// 対話用の行入力
+type lineReader struct {
+	scanner *bufio.Scanner
+}
+
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{bufio.NewScanner(r)}
+}
+
+// 1行読み込み（入力が終わるとfalse）
+func (lr *lineReader) read() (string, bool) {
+	if !lr.scanner.Scan() {
+		return "", false
+	}
+	return strings.TrimSpace(lr.scanner.Text()), true
+}
+
+// 指し手を1つ入力（成れる場合は確認し、指せない手は理由を表示してnilを返す）
+// 指し手として解釈できない入力は、コマンドとして使えるように文字列のまま返す。
+func promptMove(board *Board, lr *lineReader, out io.Writer) (*Move, string, bool) {
+	input, ok := lr.read()
+	if !ok {
+		return nil, "", false
+	}
+	move := parseInput(input, board)
+	if move == nil {
+		if input != "skip" && input != "quit" {
+			fmt.Fprintln(out, tr("無効な入力です"))
+		}
+		return nil, input, true
+	}
+	if _, _, explicit := cutPromotion(input); !explicit && canChoosePromote(board, move) {
+		piece := board.Cells[move.FromRow][move.FromCol]
+		if board.rules().MustPromote(piece, move.ToRow) {
+			move.Promote = true
+		} else {
+			fmt.Fprint(out, tr("成りますか？ (y/n): "))
+			answer, ok := lr.read()
+			if !ok {
+				return nil, "", false
+			}
+			move.Promote = answer == "y"
+		}
+	}
+	if err := board.ValidateMove(*move); err != nil {
+		fmt.Fprintf(out, tr("その手は指せません（%s）")+"\n", illegalReason(err))
+		return nil, input, true
+	}
+	return move, input, true
+}
+
+// tutorial サブコマンド: 駒の動かし方を練習問題つきで説明する
+func runTutorial(args []string) int {
+	tutorial(os.Stdin, os.Stdout)
+	return 0
+}
+
+func tutorial(in io.Reader, out io.Writer) {
+	lr := newLineReader(in)
+
+	fmt.Fprintln(out, tr("=== ミニ将棋 チュートリアル ==="))
+	fmt.Fprintln(out, tr("練習問題では、skip で次に進み、quit で終了します。"))
+	for i, l := range lessons {
+		fmt.Fprintf(out, "\n--- %d. %s ---\n", i+1, tr(l.title))
+		for _, line := range l.text {
+			fmt.Fprintln(out, tr(line))
+		}
+		if l.setup == nil {
+			fmt.Fprint(out, "\n"+tr("Enterで次へ: "))
+			if _, ok := lr.read(); !ok {
+				return
+			}
+			continue
+		}
+
+		board := l.setup()
+		for {
+			board.Display(out)
+			fmt.Fprintf(out, "\n%s: ", tr(l.task))
+			move, input, ok := promptMove(board, lr, out)
+			if !ok || input == "quit" {
+				return
+			}
+			if input == "skip" {
+				fmt.Fprintf(out, tr("正解の例: %s")+"\n", tr(l.answer))
+				break
+			}
+			if move == nil {
+				continue
+			}
+			if !l.goal(board, *move) {
+				fmt.Fprintln(out, tr("その手も指せますが、問題の答えではありません。もう一度どうぞ"))
+				continue
+			}
+			board.ApplyLegal(*move)
+			board.Display(out)
+			fmt.Fprintln(out, "\n"+tr("正解です！"))
+			break
+		}
+	}
+	fmt.Fprintln(out, "\n"+tr("チュートリアルは以上です。go run . で対局してみましょう。"))
+}
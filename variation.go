@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 変化（元の手順のPly手目までの局面から、Ply+1手目以降を別の手順で指したもの）
+// 変化の途中からさらに分かれた変化はVariationsに持つ。
+type Variation struct {
+	Ply        int         // 元の手順から分かれる局面の手数（開始局面から数える）
+	Moves      []Move      // 分かれてからの指し手
+	Variations []Variation // この変化の途中から分かれた変化（分かれる手数の順）
+}
+
+// 本譜から分かれる変化を追加（分かれる手数の順に並べる。同じ手数の変化は追加した順）
+func (r *Record) AddVariation(ply int, moves []Move) {
+	r.Variations = insertVariation(r.Variations, Variation{Ply: ply, Moves: moves})
+}
+
+// 変化の並びにvを加える（分かれる手数の順を保つ）
+func insertVariation(vs []Variation, v Variation) []Variation {
+	vs = append(vs, v)
+	sort.SliceStable(vs, func(i, j int) bool {
+		return vs[i].Ply < vs[j].Ply
+	})
+	return vs
+}
+
+// 手順（開始局面からの指し手の列）を変化の木に加える
+// 既にある手順と分かれる局面から新しい変化にし、加えた変化（またはその手順を含む変化）の道筋を返す。
+// 道筋は本譜から順にたどる変化の添字で、nilなら本譜。
+func (r *Record) AddLine(moves []Move) []int {
+	var path []int
+	line := r.Moves
+	from := 0 // lineのうち、この手順のものとして数える最初の手数
+	children := &r.Variations
+	for {
+		ply := from
+		for ply < len(line) && ply < len(moves) && line[ply] == moves[ply] {
+			ply++
+		}
+		if ply == len(moves) {
+			return path
+		}
+		if ply == len(line) && path != nil {
+			// 変化の最後から続ける
+			v := r.variation(path)
+			v.Moves = append(v.Moves, moves[ply:]...)
+			return path
+		}
+		next := slices.IndexFunc(*children, func(v Variation) bool {
+			return v.Ply == ply && v.Moves[0] == moves[ply]
+		})
+		if next < 0 {
+			*children = insertVariation(*children, Variation{Ply: ply, Moves: append([]Move{}, moves[ply:]...)})
+			next = slices.IndexFunc(*children, func(v Variation) bool {
+				return v.Ply == ply && v.Moves[0] == moves[ply]
+			})
+			return append(path, next)
+		}
+		path = append(path, next)
+		v := &(*children)[next]
+		line, from, children = r.lineMoves(path), ply, &v.Variations
+	}
+}
+
+// 道筋の指す変化
+func (r *Record) variation(path []int) *Variation {
+	vs := r.Variations
+	var v *Variation
+	for _, i := range path {
+		v = &vs[i]
+		vs = v.Variations
+	}
+	return v
+}
+
+// 道筋の手順（本譜の分かれるまでの手と、たどった変化の手をつないだもの）
+func (r *Record) lineMoves(path []int) []Move {
+	moves := r.Moves
+	vs := r.Variations
+	for _, i := range path {
+		v := vs[i]
+		moves = append(append([]Move{}, moves[:v.Ply]...), v.Moves...)
+		vs = v.Variations
+	}
+	return moves
+}
+
+// 道筋の手順のply手目の局面から分かれる変化の番号（その手順の変化の添字）
+func (r *Record) variationsAt(path []int, ply int) []int {
+	vs := r.Variations
+	if v := r.variation(path); v != nil {
+		vs = v.Variations
+	}
+	var found []int
+	for i, v := range vs {
+		if v.Ply == ply {
+			found = append(found, i)
+		}
+	}
+	return found
+}
+
+// 指し手の列をply手目まで進めた局面
+func (r *Record) positionAfter(moves []Move, ply int) *Board {
+	b := r.Initial.Clone()
+	for _, m := range moves[:ply] {
+		b.ApplyLegal(m)
+	}
+	return b
+}
+
+// 変化の木をたどり、各変化をその手順（開始局面からの指し手）とともに渡す
+// 親の変化を子の変化より先に渡し、同じ手順から分かれる変化は、後の手数で分かれるものから渡す
+// （KIF形式の変化の並び順。読み込むときに、どの手順から分かれたかが決まる）。
+func (r *Record) walkVariations(visit func(v *Variation, moves []Move)) {
+	var walk func(vs []Variation, line []Move)
+	walk = func(vs []Variation, line []Move) {
+		for i := len(vs) - 1; i >= 0; i-- {
+			// 同じ手数で分かれる変化は追加した順に渡す
+			j := i
+			for j > 0 && vs[j-1].Ply == vs[i].Ply {
+				j--
+			}
+			for k := j; k <= i; k++ {
+				v := &vs[k]
+				moves := append(append([]Move{}, line[:v.Ply]...), v.Moves...)
+				visit(v, moves)
+				walk(v.Variations, moves)
+			}
+			i = j
+		}
+	}
+	walk(r.Variations, r.Moves)
+}
+
+// 変化をCSA形式のコメント行で書き出す（例: 'VARIATION 4 +4544KA -1112HI）
+// 変化の中の変化は、本譜から分かれる局面からの手順として書く。
+func (r *Record) csaVariation(moves []Move) string {
+	ply := 0
+	for ply < len(r.Moves) && ply < len(moves) && r.Moves[ply] == moves[ply] {
+		ply++
+	}
+	b := r.Position(ply)
+	text := fmt.Sprintf("'VARIATION %d", ply)
+	for _, m := range moves[ply:] {
+		text += " " + csaMove(b, m)
+		b.ApplyLegal(m)
+	}
+	return text
+}
+
+// CSA形式のコメント行の変化を読み込む（本譜を読み込んだ後に呼ぶ。変化の中の変化は、その親の変化の後に書かれている）
+func (r *Record) readVariation(text string) error {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return fmt.Errorf(tr("変化が不正です: %s"), text)
+	}
+	ply, err := strconv.Atoi(fields[0])
+	if err != nil || ply < 0 || ply > len(r.Moves) {
+		return fmt.Errorf(tr("変化の手数が不正です: %s"), fields[0])
+	}
+	b := r.Position(ply)
+	moves := append([]Move{}, r.Moves[:ply]...)
+	for _, s := range fields[1:] {
+		m, err := parseCSAMove(b, s)
+		if err != nil {
+			return err
+		}
+		b.ApplyLegal(m)
+		moves = append(moves, m)
+	}
+	r.AddLine(moves)
+	return nil
+}
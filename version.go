@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// プログラムの名前と作者
+const (
+	programName   = "mini-syogi"
+	programAuthor = "TonkyH"
+)
+
+// 版（リリース時に -ldflags "-X main.version=v1.2.0" で埋め込む）
+var version = ""
+
+// 版の表記（埋め込まれていなければモジュールの版か、コミットから作る）
+func versionString() string {
+	if version != "" {
+		return version
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "devel"
+	}
+	if v := info.Main.Version; v != "" && v != "(devel)" {
+		return v
+	}
+	v, dirty := "devel", false
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			v += "-" + s.Value[:min(len(s.Value), 7)]
+		case "vcs.modified":
+			dirty = s.Value == "true"
+		}
+	}
+	if dirty {
+		v += "-dirty"
+	}
+	return v
+}
+
+// 名前と版（棋譜の見出しなどに書く）
+func programID() string {
+	return programName + " " + versionString()
+}
+
+// version サブコマンド: 名前と版を表示
+func runVersion(args []string) int {
+	fmt.Println(programID())
+	return 0
+}
+
+// about サブコマンド: 名前・版・作者とビルドの情報を表示
+func runAbout(args []string) int {
+	fmt.Println(programID())
+	fmt.Println(tr("ミニ将棋（5五将棋）の対局プログラム"))
+	fmt.Println(tr("作者:"), programAuthor)
+	fmt.Println("Go:", runtime.Version())
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				fmt.Println(tr("コミット:"), s.Value)
+			case "vcs.time":
+				fmt.Println(tr("コミット日時:"), s.Value)
+			}
+		}
+	}
+	return 0
+}